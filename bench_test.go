@@ -1,10 +1,13 @@
 package ctrace_test
 
 import (
+	"context"
+	"io/ioutil"
 	"os"
 	"testing"
 
 	ctrace "github.com/Nordstrom/ctrace-go"
+	"github.com/Nordstrom/ctrace-go/core"
 	"github.com/Nordstrom/ctrace-go/ext"
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/log"
@@ -122,3 +125,88 @@ func BenchmarkLog(b *testing.B) {
 
 	b.StopTimer()
 }
+
+// eventRecorder is a SpanReporter keeping the reported events, so encoders can
+// be benchmarked on their own.
+type eventRecorder struct {
+	events []*core.SpanData
+}
+
+func (r *eventRecorder) Report(sp *core.SpanData)        { r.events = append(r.events, sp) }
+func (r *eventRecorder) Flush(ctx context.Context) error { return nil }
+func (r *eventRecorder) Close(ctx context.Context) error { return nil }
+
+// recordEvents returns the Start-Span, Log, and Finish-Span events of a
+// typical server Span.
+func recordEvents() []*core.SpanData {
+	r := &eventRecorder{}
+	t := core.NewWithOptions(core.TracerOptions{MultiEvent: true, Reporter: r})
+	sp := t.StartSpan("GET /v1/orders",
+		ext.SpanKindServer(),
+		ext.Component("component"),
+		ext.PeerHostname("hostname"),
+		ext.PeerHostIPv6("ip"),
+		ext.HTTPMethod("GET"),
+		ext.HTTPUrl("https://some.url.outthere.com/v1/orders?limit=10"),
+	)
+	sp.SetBaggageItem("tenant", "acme")
+	sp.LogFields(
+		log.String("event", "cache miss"),
+		log.String("key", "orders:acme"),
+		log.Int("waited.millis", 15),
+	)
+	sp.SetTag(ext.HTTPStatusCodeKey, 200)
+	sp.Finish()
+	return r.events
+}
+
+func benchmarkEncoder(b *testing.B, enc core.SpanEncoder) {
+	events := recordEvents()
+	size := 0
+	for _, e := range events {
+		size += len(enc.Encode(e))
+	}
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, e := range events {
+			enc.Encode(e)
+		}
+	}
+}
+
+func BenchmarkEncodeJSON(b *testing.B) {
+	benchmarkEncoder(b, core.NewSpanEncoder())
+}
+
+func BenchmarkEncodeProtobuf(b *testing.B) {
+	benchmarkEncoder(b, core.NewProtobufSpanEncoder())
+}
+
+func benchmarkReporter(b *testing.B, enc core.SpanEncoder) {
+	t := core.NewWithOptions(core.TracerOptions{
+		MultiEvent: true,
+		Reporter:   core.NewSpanReporter(ioutil.Discard, enc),
+	})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sp := t.StartSpan("parent",
+			ext.SpanKindServer(),
+			ext.Component("component"),
+			ext.HTTPMethod("method"),
+			ext.HTTPUrl("https://some.url.outthere.com"),
+		)
+		sp.LogFields(log.String("event", "event"))
+		sp.Finish()
+	}
+}
+
+func BenchmarkReportJSON(b *testing.B) {
+	benchmarkReporter(b, core.NewSpanEncoder())
+}
+
+func BenchmarkReportProtobuf(b *testing.B) {
+	benchmarkReporter(b, core.NewProtobufSpanEncoder())
+}
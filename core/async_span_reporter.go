@@ -0,0 +1,254 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy tells an asynchronous SpanReporter what to do with events
+// reported while its queue is full.
+type OverflowPolicy int
+
+const (
+	// DropWhenFull discards the event and counts it as dropped.
+	DropWhenFull OverflowPolicy = iota
+
+	// BlockWhenFull blocks the reporting goroutine until there is room in the
+	// queue.
+	BlockWhenFull
+)
+
+// AsyncSpanReporterOptions allows customizing a SpanReporter created via
+// NewAsyncSpanReporter.
+type AsyncSpanReporterOptions struct {
+	// QueueSize is the number of events that can be waiting to be encoded and
+	// written.  It defaults to 1024.
+	QueueSize int
+
+	// BatchSize is the number of events collected before they are written in
+	// a single call to the io.Writer.  It defaults to 64.
+	BatchSize int
+
+	// FlushInterval is the longest time an event waits for its batch to fill
+	// up before it is written anyway.  It defaults to one second.
+	FlushInterval time.Duration
+
+	// OverflowPolicy tells what to do when the queue is full.  It defaults to
+	// DropWhenFull.
+	OverflowPolicy OverflowPolicy
+
+	// ErrorHandler is called from the background goroutine with the errors
+	// of writing batches, whose events are counted as dropped.  It defaults
+	// to printing them to os.Stderr.
+	ErrorHandler func(error)
+}
+
+// AsyncSpanReporter is a SpanReporter that writes events in batches from a
+// background goroutine, keeping slow writers off the caller's latency path.
+type AsyncSpanReporter interface {
+	SpanReporter
+
+	// Dropped returns the number of events discarded because the queue was
+	// full, the reporter was closed, or writing them failed.
+	Dropped() uint64
+}
+
+type asyncSpanReporter struct {
+	io.Writer
+	SpanEncoder
+	opts AsyncSpanReporterOptions
+
+	queue     chan *SpanData
+	flushes   chan chan error
+	closing   chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	dropped   uint64
+
+	// closeMu is held shared while an event is enqueued, and exclusively
+	// while closing, so no event is enqueued after run has drained the queue.
+	closeMu sync.RWMutex
+	closed  bool
+
+	// The encoded events waiting to be written, and their number, only used
+	// by run.
+	batch []byte
+	count int
+}
+
+// NewAsyncSpanReporter creates a SpanReporter that encodes events and writes
+// them to w from a background goroutine.
+func NewAsyncSpanReporter(
+	w io.Writer,
+	e SpanEncoder,
+	opts AsyncSpanReporterOptions,
+) AsyncSpanReporter {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 64
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.ErrorHandler == nil {
+		opts.ErrorHandler = func(err error) {
+			fmt.Fprintln(os.Stderr, "ctrace:", err)
+		}
+	}
+
+	r := &asyncSpanReporter{
+		Writer:      w,
+		SpanEncoder: e,
+		opts:        opts,
+		queue:       make(chan *SpanData, opts.QueueSize),
+		flushes:     make(chan chan error),
+		closing:     make(chan struct{}),
+		done:        make(chan struct{}),
+		batch:       make([]byte, 0, 4096),
+	}
+	go r.run()
+	return r
+}
+
+func (r *asyncSpanReporter) Report(sp *SpanData) {
+	r.closeMu.RLock()
+	defer r.closeMu.RUnlock()
+	if r.closed {
+		atomic.AddUint64(&r.dropped, 1)
+		return
+	}
+
+	if r.opts.OverflowPolicy == BlockWhenFull {
+		// run keeps emptying the queue until closed, which waits for us.
+		r.queue <- sp
+		return
+	}
+
+	select {
+	case r.queue <- sp:
+	default:
+		atomic.AddUint64(&r.dropped, 1)
+	}
+}
+
+func (r *asyncSpanReporter) Dropped() uint64 {
+	return atomic.LoadUint64(&r.dropped)
+}
+
+// Flush writes all queued events, or returns ctx.Err() if ctx is done first.
+func (r *asyncSpanReporter) Flush(ctx context.Context) error {
+	ack := make(chan error, 1)
+	select {
+	case r.flushes <- ack:
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-ack:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close writes all queued events and stops the background goroutine.  Events
+// reported after Close are dropped.
+func (r *asyncSpanReporter) Close(ctx context.Context) error {
+	r.closeOnce.Do(func() {
+		// Blocked reporters hold closeMu until run makes room for them, so
+		// take it in the background to honour ctx.
+		go func() {
+			r.closeMu.Lock()
+			defer r.closeMu.Unlock()
+			r.closed = true
+			close(r.closing)
+		}()
+	})
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run collects queued events into batches and writes them until the
+// reporter is closed.
+func (r *asyncSpanReporter) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case sp := <-r.queue:
+			r.add(sp)
+		case <-ticker.C:
+			r.write()
+		case ack := <-r.flushes:
+			r.drain()
+			r.write()
+			ack <- flushWriter(r.Writer)
+		case <-r.closing:
+			r.drain()
+			r.write()
+			if err := flushWriter(r.Writer); err != nil {
+				r.opts.ErrorHandler(err)
+			}
+			return
+		}
+	}
+}
+
+// add appends an event to the batch, and writes the batch once it is full.
+func (r *asyncSpanReporter) add(sp *SpanData) {
+	r.batch = append(r.batch, r.Encode(sp)...)
+	r.count++
+	if r.count >= r.opts.BatchSize {
+		r.write()
+	}
+}
+
+// drain adds every event currently in the queue, in batches of BatchSize.
+func (r *asyncSpanReporter) drain() {
+	for {
+		select {
+		case sp := <-r.queue:
+			r.add(sp)
+		default:
+			return
+		}
+	}
+}
+
+// write writes the batch and empties it.  The events of a failed write are
+// counted as dropped.
+func (r *asyncSpanReporter) write() {
+	if r.count == 0 {
+		return
+	}
+	if len(r.batch) > 0 {
+		n, err := r.Write(r.batch)
+		if err == nil && n != len(r.batch) {
+			err = fmt.Errorf("expect %d bytes reported, but had %d instead", len(r.batch), n)
+		}
+		if err != nil {
+			atomic.AddUint64(&r.dropped, uint64(r.count))
+			r.opts.ErrorHandler(err)
+		}
+	}
+	r.batch = r.batch[:0]
+	r.count = 0
+}
@@ -0,0 +1,263 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// recordingWriter records each call to Write, optionally blocking until
+// released.
+type recordingWriter struct {
+	sync.Mutex
+	writes  []string
+	gate    chan struct{}
+	release sync.Once
+}
+
+func newGatedWriter() *recordingWriter {
+	return &recordingWriter{gate: make(chan struct{})}
+}
+
+func (w *recordingWriter) Release() {
+	if w.gate != nil {
+		w.release.Do(func() { close(w.gate) })
+	}
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	if w.gate != nil {
+		<-w.gate
+	}
+	w.Lock()
+	defer w.Unlock()
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+func (w *recordingWriter) Writes() []string {
+	w.Lock()
+	defer w.Unlock()
+	return append([]string{}, w.writes...)
+}
+
+func (w *recordingWriter) Lines() []string {
+	var buf bytes.Buffer
+	for _, s := range w.Writes() {
+		buf.WriteString(s)
+	}
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+var _ = Describe("AsyncSpanReporter", func() {
+	var (
+		w   *recordingWriter
+		rep AsyncSpanReporter
+		sp  *SpanData
+	)
+
+	BeforeEach(func() {
+		w = &recordingWriter{}
+		sp = &SpanData{
+			operation: "op",
+			context: spanContext{
+				traceID: TraceID{Low: 123},
+				spanID:  456,
+			},
+			duration: -1,
+		}
+	})
+
+	AfterEach(func() {
+		w.Release()
+		rep.Close(context.Background())
+	})
+
+	Describe("Report", func() {
+		It("writes on Flush", func() {
+			rep = NewAsyncSpanReporter(w, NewSpanEncoder(), AsyncSpanReporterOptions{
+				FlushInterval: time.Hour,
+			})
+			rep.Report(sp)
+			rep.Report(sp)
+			Ω(rep.Flush(context.Background())).Should(Succeed())
+			Ω(w.Lines()).Should(HaveLen(2))
+			Ω(w.Lines()[0]).Should(MatchRegexp(
+				`\{"traceId":"000000000000007b","spanId":"00000000000001c8","operation":"op","start":\d{16}\}`))
+		})
+
+		It("writes full batches", func() {
+			rep = NewAsyncSpanReporter(w, NewSpanEncoder(), AsyncSpanReporterOptions{
+				BatchSize:     2,
+				FlushInterval: time.Hour,
+			})
+			for i := 0; i < 4; i++ {
+				rep.Report(sp)
+			}
+			Eventually(w.Writes).Should(HaveLen(2))
+			Ω(strings.Count(w.Writes()[0], "\n")).Should(Equal(2))
+			Ω(strings.Count(w.Writes()[1], "\n")).Should(Equal(2))
+		})
+
+		It("writes partial batches after FlushInterval", func() {
+			rep = NewAsyncSpanReporter(w, NewSpanEncoder(), AsyncSpanReporterOptions{
+				BatchSize:     100,
+				FlushInterval: 10 * time.Millisecond,
+			})
+			rep.Report(sp)
+			Eventually(w.Writes).Should(HaveLen(1))
+		})
+
+		It("drops events when full", func() {
+			w = newGatedWriter()
+			rep = NewAsyncSpanReporter(w, NewSpanEncoder(), AsyncSpanReporterOptions{
+				QueueSize:     1,
+				BatchSize:     1,
+				FlushInterval: time.Hour,
+			})
+			for i := 0; i < 10; i++ {
+				rep.Report(sp)
+			}
+			Ω(rep.Dropped()).Should(BeNumerically(">=", 8))
+		})
+
+		It("blocks when full", func() {
+			w = newGatedWriter()
+			rep = NewAsyncSpanReporter(w, NewSpanEncoder(), AsyncSpanReporterOptions{
+				QueueSize:      1,
+				BatchSize:      1,
+				FlushInterval:  time.Hour,
+				OverflowPolicy: BlockWhenFull,
+			})
+			reported := make(chan struct{})
+			go func() {
+				for i := 0; i < 10; i++ {
+					rep.Report(sp)
+				}
+				close(reported)
+			}()
+			Consistently(reported).ShouldNot(BeClosed())
+			w.Release()
+			Eventually(reported).Should(BeClosed())
+			Ω(rep.Flush(context.Background())).Should(Succeed())
+			Ω(w.Lines()).Should(HaveLen(10))
+			Ω(rep.Dropped()).Should(BeZero())
+		})
+	})
+
+	Describe("Flush", func() {
+		It("writes queued events in batches", func() {
+			w = newGatedWriter()
+			rep = NewAsyncSpanReporter(w, NewSpanEncoder(), AsyncSpanReporterOptions{
+				BatchSize:     2,
+				FlushInterval: time.Hour,
+			})
+			for i := 0; i < 5; i++ {
+				rep.Report(sp)
+			}
+			w.Release()
+			Ω(rep.Flush(context.Background())).Should(Succeed())
+			Ω(w.Lines()).Should(HaveLen(5))
+			for _, s := range w.Writes() {
+				Ω(strings.Count(s, "\n")).Should(BeNumerically("<=", 2))
+			}
+		})
+
+		It("flushes buffered writers", func() {
+			bw := bufio.NewWriter(w)
+			rep = NewAsyncSpanReporter(bw, NewSpanEncoder(), AsyncSpanReporterOptions{
+				FlushInterval: time.Hour,
+			})
+			rep.Report(sp)
+			Ω(rep.Flush(context.Background())).Should(Succeed())
+			Ω(w.Lines()).Should(HaveLen(1))
+		})
+
+		It("returns when the deadline expires", func() {
+			w = newGatedWriter()
+			rep = NewAsyncSpanReporter(w, NewSpanEncoder(), AsyncSpanReporterOptions{
+				BatchSize: 1,
+			})
+			rep.Report(sp)
+			rep.Report(sp)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			Ω(rep.Flush(ctx)).Should(Equal(context.DeadlineExceeded))
+		})
+	})
+
+	Describe("errors", func() {
+		It("counts the events of failed writes as dropped", func() {
+			var errs []error
+			rep = NewAsyncSpanReporter(failingWriter{}, NewSpanEncoder(), AsyncSpanReporterOptions{
+				BatchSize:     2,
+				FlushInterval: time.Hour,
+				ErrorHandler:  func(err error) { errs = append(errs, err) },
+			})
+			for i := 0; i < 3; i++ {
+				rep.Report(sp)
+			}
+			Ω(rep.Flush(context.Background())).Should(Succeed())
+			Ω(rep.Dropped()).Should(Equal(uint64(3)))
+			Ω(errs).Should(HaveLen(2))
+			Ω(errs[0]).Should(MatchError("write failed"))
+		})
+	})
+
+	Describe("Close", func() {
+		It("writes queued events and drops later ones", func() {
+			rep = NewAsyncSpanReporter(w, NewSpanEncoder(), AsyncSpanReporterOptions{
+				FlushInterval: time.Hour,
+			})
+			rep.Report(sp)
+			Ω(rep.Close(context.Background())).Should(Succeed())
+			Ω(w.Lines()).Should(HaveLen(1))
+
+			rep.Report(sp)
+			Ω(rep.Dropped()).Should(Equal(uint64(1)))
+			Ω(rep.Flush(context.Background())).Should(Succeed())
+		})
+
+		It("writes or drops events reported while closing", func() {
+			for _, policy := range []OverflowPolicy{DropWhenFull, BlockWhenFull} {
+				w = &recordingWriter{}
+				rep = NewAsyncSpanReporter(w, NewSpanEncoder(), AsyncSpanReporterOptions{
+					QueueSize:      4,
+					BatchSize:      1,
+					OverflowPolicy: policy,
+				})
+				var wg sync.WaitGroup
+				for g := 0; g < 4; g++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for i := 0; i < 100; i++ {
+							rep.Report(sp)
+						}
+					}()
+				}
+				Ω(rep.Close(context.Background())).Should(Succeed())
+				wg.Wait()
+				written := 0
+				for _, s := range w.Writes() {
+					written += strings.Count(s, "\n")
+				}
+				Ω(uint64(written) + rep.Dropped()).Should(Equal(uint64(400)))
+			}
+		})
+	})
+})
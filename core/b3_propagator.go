@@ -0,0 +1,206 @@
+package core
+
+import (
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+const (
+	b3TraceIDKey = "x-b3-traceid"
+	b3SpanIDKey  = "x-b3-spanid"
+	b3SampledKey = "x-b3-sampled"
+	b3FlagsKey   = "x-b3-flags"
+	b3SingleKey  = "b3"
+
+	b3ParentSpanIDKey = "x-b3-parentspanid"
+)
+
+type b3Propagator struct {
+	singleHeader bool
+}
+
+// NewB3Propagator creates a Propagator for the Zipkin B3 headers.  It
+// injects the X-B3-* headers and extracts both those and the single b3
+// header.  B3 has no baggage, so baggage is not propagated.
+//
+// An absent sampling flag defers the decision to the receiver, so the
+// tracer's Sampler decides, and it stays absent when such a context is
+// injected.
+//
+// The optional parent span ID is validated when extracted, but otherwise
+// ignored and never injected: a SpanContext does not know the parent of its
+// Span, and the receiving side only needs the span ID, which becomes the
+// parent of its Spans.
+func NewB3Propagator() Propagator {
+	return &b3Propagator{}
+}
+
+// NewB3SingleHeaderPropagator creates a Propagator like NewB3Propagator, but
+// which injects the single b3 header instead of the X-B3-* headers.
+func NewB3SingleHeaderPropagator() Propagator {
+	return &b3Propagator{singleHeader: true}
+}
+
+func (p *b3Propagator) Inject(
+	ctx opentracing.SpanContext,
+	opaqueCarrier interface{},
+) error {
+	sc, ok := ctx.(spanContext)
+	if !ok {
+		return opentracing.ErrInvalidSpanContext
+	}
+	carrier, ok := opaqueCarrier.(TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+
+	sampling := "0"
+	if sc.debug {
+		sampling = "d"
+	} else if sc.samplingDeferred {
+		// Leave the decision to the receiver, as it was left to us.
+		sampling = ""
+	} else if sc.sampled {
+		sampling = "1"
+	}
+
+	if p.singleHeader {
+		if sc.traceID.IsValid() {
+			value := sc.traceID.String() + "-" + formatSpanID(sc.spanID)
+			if sampling != "" {
+				value += "-" + sampling
+			}
+			carrier.Set(b3SingleKey, value)
+		} else if sc.debug {
+			carrier.Set(b3SingleKey, sampling)
+		}
+		return nil
+	}
+
+	if sc.traceID.IsValid() {
+		carrier.Set("X-B3-TraceId", sc.traceID.String())
+		carrier.Set("X-B3-SpanId", formatSpanID(sc.spanID))
+	}
+	if sc.debug {
+		carrier.Set("X-B3-Flags", "1")
+	} else if sampling != "" {
+		carrier.Set("X-B3-Sampled", sampling)
+	}
+	return nil
+}
+
+func (p *b3Propagator) Extract(
+	opaqueCarrier interface{},
+) (opentracing.SpanContext, error) {
+	carrier, ok := opaqueCarrier.(opentracing.TextMapReader)
+	if !ok {
+		return nil, opentracing.ErrInvalidCarrier
+	}
+
+	var traceID, spanID, parentSpanID, sampled, flags, single string
+	err := carrier.ForeachKey(func(k, v string) error {
+		switch strings.ToLower(k) {
+		case b3TraceIDKey:
+			traceID = v
+		case b3SpanIDKey:
+			spanID = v
+		case b3ParentSpanIDKey:
+			parentSpanID = v
+		case b3SampledKey:
+			sampled = v
+		case b3FlagsKey:
+			flags = v
+		case b3SingleKey:
+			single = v
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if single != "" {
+		return parseB3Single(single)
+	}
+	if traceID == "" && spanID == "" {
+		if flags == "1" {
+			return spanContext{sampled: true, debug: true}, nil
+		}
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+
+	var sc spanContext
+	if sc.traceID, err = ParseTraceID(traceID); err != nil {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	if sc.spanID, err = parseSpanID(spanID); err != nil {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	if parentSpanID != "" {
+		if _, err = parseSpanID(parentSpanID); err != nil {
+			return nil, opentracing.ErrSpanContextCorrupted
+		}
+	}
+	switch sampled {
+	case "":
+		// An absent flag defers the decision to the receiver.
+		sc.samplingDeferred = true
+	case "1", "true":
+		sc.sampled = true
+	case "0", "false":
+	default:
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	if flags == "1" {
+		sc.sampled, sc.debug, sc.samplingDeferred = true, true, false
+	}
+	return sc, nil
+}
+
+// parseB3Single parses a single b3 header of the form
+// {TraceId}-{SpanId}-{SamplingState}-{ParentSpanId}, where the last two
+// fields are optional, or of the form {SamplingState}.
+func parseB3Single(s string) (opentracing.SpanContext, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) == 1 {
+		switch parts[0] {
+		case "d":
+			return spanContext{sampled: true, debug: true}, nil
+		case "0", "1":
+			// A sampling decision without a trace to continue.
+			return nil, opentracing.ErrSpanContextNotFound
+		}
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	if len(parts) > 4 {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+
+	sc := spanContext{samplingDeferred: true}
+	var err error
+	if sc.traceID, err = ParseTraceID(parts[0]); err != nil {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	if sc.spanID, err = parseSpanID(parts[1]); err != nil {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	if len(parts) > 2 {
+		sc.samplingDeferred = false
+		switch parts[2] {
+		case "1":
+			sc.sampled = true
+		case "0":
+		case "d":
+			sc.sampled, sc.debug = true, true
+		default:
+			return nil, opentracing.ErrSpanContextCorrupted
+		}
+	}
+	if len(parts) > 3 {
+		if _, err = parseSpanID(parts[3]); err != nil {
+			return nil, opentracing.ErrSpanContextCorrupted
+		}
+	}
+	return sc, nil
+}
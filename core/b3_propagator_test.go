@@ -0,0 +1,187 @@
+package core_test
+
+import (
+	"net/http"
+
+	"github.com/Nordstrom/ctrace-go/core"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+var _ = Describe("B3Propagator", func() {
+	var p core.Propagator
+
+	BeforeEach(func() {
+		p = core.NewB3Propagator()
+	})
+
+	extract := func(hdrs map[string]string) (core.SpanContext, error) {
+		h := http.Header{}
+		for k, v := range hdrs {
+			h.Set(k, v)
+		}
+		c, err := p.Extract(opentracing.HTTPHeadersCarrier(h))
+		if err != nil {
+			return nil, err
+		}
+		return c.(core.SpanContext), nil
+	}
+
+	Describe("Extract", func() {
+		It("extracts multiple headers", func() {
+			c, err := extract(map[string]string{
+				"X-B3-TraceId":      "463ac35c9f6413ad48485a3953bb6124",
+				"X-B3-SpanId":       "a2fb4a1d1a96d312",
+				"X-B3-ParentSpanId": "0020000000000001",
+				"X-B3-Sampled":      "0",
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(c.TraceID()).Should(Equal("463ac35c9f6413ad48485a3953bb6124"))
+			Ω(c.SpanID()).Should(Equal("a2fb4a1d1a96d312"))
+			Ω(c.IsSampled()).Should(BeFalse())
+		})
+
+		It("extracts debug flag", func() {
+			c, err := extract(map[string]string{
+				"X-B3-TraceId": "a3ce929d0e0e4736",
+				"X-B3-SpanId":  "a2fb4a1d1a96d312",
+				"X-B3-Flags":   "1",
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(c.IsDebug()).Should(BeTrue())
+			Ω(c.IsSampled()).Should(BeTrue())
+		})
+
+		It("extracts single header", func() {
+			c, err := extract(map[string]string{
+				"b3": "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90",
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(c.TraceID()).Should(Equal("80f198ee56343ba864fe8b2a57d3eff7"))
+			Ω(c.SpanID()).Should(Equal("e457b5a2e4d86bd1"))
+			Ω(c.IsSampled()).Should(BeTrue())
+		})
+
+		It("extracts single header without sampling state", func() {
+			c, err := extract(map[string]string{"b3": "a3ce929d0e0e4736-e457b5a2e4d86bd1"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(c.SpanID()).Should(Equal("e457b5a2e4d86bd1"))
+		})
+
+		It("leaves absent sampling flags to the Sampler", func() {
+			for _, sampled := range []bool{true, false} {
+				trc := core.NewWithOptions(core.TracerOptions{
+					Writer:  &core.Buffer{},
+					Sampler: core.NewConstSampler(sampled),
+				})
+				for _, hdrs := range []map[string]string{
+					{"X-B3-TraceId": "a3ce929d0e0e4736", "X-B3-SpanId": "a2fb4a1d1a96d312"},
+					{"b3": "a3ce929d0e0e4736-a2fb4a1d1a96d312"},
+				} {
+					c, err := extract(hdrs)
+					Ω(err).ShouldNot(HaveOccurred())
+					sp := trc.StartSpan("op", opentracing.ChildOf(c))
+					Ω(sp.Context().(core.SpanContext).IsSampled()).Should(Equal(sampled), "%v", hdrs)
+					sp.Finish()
+				}
+				for _, hdrs := range []map[string]string{
+					{"X-B3-TraceId": "a3ce929d0e0e4736", "X-B3-SpanId": "a2fb4a1d1a96d312", "X-B3-Sampled": "1"},
+					{"b3": "a3ce929d0e0e4736-a2fb4a1d1a96d312-1"},
+				} {
+					c, _ := extract(hdrs)
+					sp := trc.StartSpan("op", opentracing.ChildOf(c))
+					Ω(sp.Context().(core.SpanContext).IsSampled()).Should(BeTrue(), "%v", hdrs)
+				}
+			}
+		})
+
+		It("extracts single debug header", func() {
+			c, err := extract(map[string]string{"b3": "d"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(c.IsDebug()).Should(BeTrue())
+		})
+
+		It("returns not found", func() {
+			_, err := extract(map[string]string{})
+			Ω(err).Should(Equal(opentracing.ErrSpanContextNotFound))
+			_, err = extract(map[string]string{"b3": "0"})
+			Ω(err).Should(Equal(opentracing.ErrSpanContextNotFound))
+		})
+
+		It("rejects corrupted headers", func() {
+			for _, hdrs := range []map[string]string{
+				{"X-B3-TraceId": "xyz", "X-B3-SpanId": "a2fb4a1d1a96d312"},
+				{"X-B3-TraceId": "a3ce929d0e0e4736"},
+				{"X-B3-TraceId": "a3ce929d0e0e4736", "X-B3-SpanId": "a2fb4a1d1a96d312", "X-B3-Sampled": "maybe"},
+				{"X-B3-TraceId": "a3ce929d0e0e4736", "X-B3-SpanId": "a2fb4a1d1a96d312", "X-B3-ParentSpanId": "xyz"},
+				{"b3": "a3ce929d0e0e4736-e457b5a2e4d86bd1-1-xyz"},
+				{"b3": "a3ce929d0e0e4736-e457b5a2e4d86bd1-x"},
+				{"b3": "a3ce929d0e0e4736"},
+			} {
+				_, err := extract(hdrs)
+				Ω(err).Should(Equal(opentracing.ErrSpanContextCorrupted), "%v", hdrs)
+			}
+		})
+	})
+
+	Describe("Inject", func() {
+		var ctx core.SpanContext
+
+		BeforeEach(func() {
+			ctx = core.NewSpanContextFromTraceID(core.TraceID{High: 1, Low: 2}, 3, nil)
+		})
+
+		It("injects multiple headers", func() {
+			out := opentracing.TextMapCarrier{}
+			Ω(p.Inject(ctx, out)).Should(Succeed())
+			Ω(out).Should(Equal(opentracing.TextMapCarrier{
+				"X-B3-TraceId": "00000000000000010000000000000002",
+				"X-B3-SpanId":  "0000000000000003",
+				"X-B3-Sampled": "1",
+			}))
+		})
+
+		It("leaves deferred sampling to the receiver", func() {
+			c, err := extract(map[string]string{"X-B3-TraceId": "a3ce929d0e0e4736", "X-B3-SpanId": "a2fb4a1d1a96d312"})
+			Ω(err).ShouldNot(HaveOccurred())
+			out := opentracing.TextMapCarrier{}
+			Ω(p.Inject(c, out)).Should(Succeed())
+			Ω(out).ShouldNot(HaveKey("X-B3-Sampled"))
+
+			out = opentracing.TextMapCarrier{}
+			Ω(core.NewB3SingleHeaderPropagator().Inject(c, out)).Should(Succeed())
+			Ω(out).Should(Equal(opentracing.TextMapCarrier{
+				"b3": "a3ce929d0e0e4736-a2fb4a1d1a96d312",
+			}))
+		})
+
+		It("injects single header", func() {
+			p = core.NewB3SingleHeaderPropagator()
+			out := opentracing.TextMapCarrier{}
+			Ω(p.Inject(ctx, out)).Should(Succeed())
+			Ω(out).Should(Equal(opentracing.TextMapCarrier{
+				"b3": "00000000000000010000000000000002-0000000000000003-1",
+			}))
+		})
+	})
+
+	It("continues Zipkin traces in a Tracer", func() {
+		var buf core.Buffer
+		trc := core.NewWithOptions(core.TracerOptions{
+			Writer:                &buf,
+			HTTPHeadersPropagator: core.NewB3Propagator(),
+		})
+		h := http.Header{}
+		h.Set("X-B3-TraceId", "a3ce929d0e0e4736")
+		h.Set("X-B3-SpanId", "a2fb4a1d1a96d312")
+		parent, err := trc.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(h))
+		Ω(err).ShouldNot(HaveOccurred())
+		trc.StartSpan("op", opentracing.ChildOf(parent)).Finish()
+
+		sp := buf.Spans()[0]
+		Ω(sp.TraceID).Should(Equal("a3ce929d0e0e4736"))
+		Ω(sp.ParentID).Should(Equal("a2fb4a1d1a96d312"))
+	})
+})
@@ -0,0 +1,216 @@
+package core
+
+import (
+	"sort"
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// BaggagePolicy restricts the baggage accepted from remote callers, set on
+// Spans, and sent to remote services.  The zero value allows all baggage.
+//
+// Baggage dropped from an extracted SpanContext is logged as a
+// "Baggage-Dropped" event on the Spans started from it, and baggage refused
+// by SetBaggageItem is logged on the Span itself.  Baggage withheld from
+// outgoing requests is logged on the injecting Span by Tracer.InjectSpan,
+// which the traced HTTP client uses.
+type BaggagePolicy struct {
+	// AllowedKeys lists the only baggage keys that are accepted.  Empty
+	// allows any key.  Keys are matched case-insensitively, as HTTP headers
+	// are.
+	AllowedKeys []string
+
+	// MaxItems limits the number of baggage items.  0 means no limit.
+	MaxItems int
+
+	// MaxBytes limits the total length of the baggage keys and values.  0
+	// means no limit.
+	MaxBytes int
+
+	// InboundKeys lists the only allowed keys that are accepted from remote
+	// callers.  Empty accepts every allowed key.
+	InboundKeys []string
+
+	// OutboundKeys lists the only allowed keys that are sent to remote
+	// services.  Empty sends every allowed key.
+	OutboundKeys []string
+}
+
+// Reasons for dropping baggage.
+const (
+	baggageNotAllowed         = "not allowed"
+	baggageNotAllowedInbound  = "not allowed inbound"
+	baggageNotAllowedOutbound = "not allowed outbound"
+	baggageMaxItems           = "max items"
+	baggageMaxBytes           = "max bytes"
+	baggageInvalidSignature   = "invalid signature"
+)
+
+// baggageReasons are the logged reasons, in the order they are logged.
+var baggageReasons = []string{
+	baggageInvalidSignature,
+	baggageNotAllowed,
+	baggageNotAllowedInbound,
+	baggageNotAllowedOutbound,
+	baggageMaxItems,
+	baggageMaxBytes,
+}
+
+// baggageViolations maps the reasons for dropping baggage to the dropped keys.
+type baggageViolations map[string][]string
+
+type baggageDirection int
+
+const (
+	baggageLocal baggageDirection = iota
+	baggageInbound
+	baggageOutbound
+)
+
+// baggagePolicy is a BaggagePolicy compiled for lookups.
+type baggagePolicy struct {
+	allowed  map[string]bool
+	inbound  map[string]bool
+	outbound map[string]bool
+	maxItems int
+	maxBytes int
+}
+
+// newBaggagePolicy compiles p, returning nil if it allows all baggage.
+func newBaggagePolicy(p BaggagePolicy) *baggagePolicy {
+	if len(p.AllowedKeys) == 0 && len(p.InboundKeys) == 0 && len(p.OutboundKeys) == 0 &&
+		p.MaxItems <= 0 && p.MaxBytes <= 0 {
+		return nil
+	}
+	return &baggagePolicy{
+		allowed:  optionalKeySet(p.AllowedKeys),
+		inbound:  optionalKeySet(p.InboundKeys),
+		outbound: optionalKeySet(p.OutboundKeys),
+		maxItems: p.MaxItems,
+		maxBytes: p.MaxBytes,
+	}
+}
+
+// optionalKeySet returns nil for no keys, which means all keys are allowed.
+func optionalKeySet(keys []string) map[string]bool {
+	if len(keys) == 0 {
+		return nil
+	}
+	return keySet(keys)
+}
+
+// check returns the reason for refusing key in the given direction, or "" if
+// the key is accepted.  It does not check the limits.
+func (p *baggagePolicy) check(key string, dir baggageDirection) string {
+	key = strings.ToLower(key)
+	if p.allowed != nil && !p.allowed[key] {
+		return baggageNotAllowed
+	}
+	if dir == baggageInbound && p.inbound != nil && !p.inbound[key] {
+		return baggageNotAllowedInbound
+	}
+	if dir == baggageOutbound && p.outbound != nil && !p.outbound[key] {
+		return baggageNotAllowedOutbound
+	}
+	return ""
+}
+
+// filter returns the baggage accepted in the given direction and the
+// violations.  Keys are considered in sorted order so the same items are
+// kept when the limits are exceeded.  baggage is returned unchanged if
+// nothing is dropped.
+func (p *baggagePolicy) filter(
+	baggage map[string]string,
+	dir baggageDirection,
+) (map[string]string, baggageViolations) {
+	if len(baggage) == 0 {
+		return baggage, nil
+	}
+	keys := make([]string, 0, len(baggage))
+	for k := range baggage {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var violations baggageViolations
+	kept := make(map[string]string, len(baggage))
+	size := 0
+	for _, k := range keys {
+		v := baggage[k]
+		reason := p.check(k, dir)
+		if reason == "" {
+			reason = p.limitReason(len(kept), size, k, v)
+		}
+		if reason != "" {
+			if violations == nil {
+				violations = make(baggageViolations)
+			}
+			violations[reason] = append(violations[reason], k)
+			continue
+		}
+		kept[k] = v
+		size += len(k) + len(v)
+	}
+	if violations == nil {
+		return baggage, nil
+	}
+	return kept, violations
+}
+
+// limitReason returns the limit exceeded by adding an item to baggage with
+// the given number of items and size, or "" if none is.
+func (p *baggagePolicy) limitReason(items, size int, key, val string) string {
+	if p.maxItems > 0 && items >= p.maxItems {
+		return baggageMaxItems
+	}
+	if p.maxBytes > 0 && size+len(key)+len(val) > p.maxBytes {
+		return baggageMaxBytes
+	}
+	return ""
+}
+
+// setItemReason returns the reason for refusing to set a baggage item on a
+// Span with the given baggage, or "" if it is accepted.
+func (p *baggagePolicy) setItemReason(baggage map[string]string, key, val string) string {
+	if reason := p.check(key, baggageLocal); reason != "" {
+		return reason
+	}
+	items, size := 0, 0
+	for k, v := range baggage {
+		if k != key {
+			items++
+			size += len(k) + len(v)
+		}
+	}
+	return p.limitReason(items, size, key, val)
+}
+
+// add merges other into v, returning the result.
+func (v baggageViolations) add(other baggageViolations) baggageViolations {
+	for reason, keys := range other {
+		if v == nil {
+			v = make(baggageViolations)
+		}
+		v[reason] = append(v[reason], keys...)
+	}
+	return v
+}
+
+// logRecords returns a "Baggage-Dropped" log per reason.
+func (v baggageViolations) logRecords() []opentracing.LogRecord {
+	var records []opentracing.LogRecord
+	for _, reason := range baggageReasons {
+		if keys := v[reason]; len(keys) > 0 {
+			records = append(records, opentracing.LogRecord{
+				Fields: []log.Field{
+					log.String("event", "Baggage-Dropped"),
+					log.String("reason", reason),
+					log.String("keys", strings.Join(keys, ",")),
+				},
+			})
+		}
+	}
+	return records
+}
@@ -0,0 +1,152 @@
+package core_test
+
+import (
+	"github.com/Nordstrom/ctrace-go/core"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+var _ = Describe("BaggagePolicy", func() {
+	var (
+		buf    core.Buffer
+		trc    core.Tracer
+		policy core.BaggagePolicy
+	)
+
+	JustBeforeEach(func() {
+		buf.Reset()
+		trc = core.NewWithOptions(core.TracerOptions{
+			Writer:        &buf,
+			MultiEvent:    true,
+			BaggagePolicy: policy,
+		})
+	})
+
+	extract := func(carrier opentracing.TextMapCarrier) core.SpanContext {
+		carrier["ct-trace-id"] = "7b"
+		carrier["ct-span-id"] = "f5"
+		c, err := trc.Extract(core.TextMap, carrier)
+		Ω(err).ShouldNot(HaveOccurred())
+		return c.(core.SpanContext)
+	}
+
+	droppedLogs := func() []map[string]interface{} {
+		var logs []map[string]interface{}
+		for _, s := range buf.Spans() {
+			if s.Logs[0]["event"] == "Baggage-Dropped" {
+				logs = append(logs, s.Logs[0])
+			}
+		}
+		return logs
+	}
+
+	Context("with allowed and inbound keys", func() {
+		BeforeEach(func() {
+			policy = core.BaggagePolicy{
+				AllowedKeys: []string{"tenant", "region", "internal"},
+				InboundKeys: []string{"tenant", "region"},
+			}
+		})
+
+		It("drops other keys on extract and logs them on child spans", func() {
+			c := extract(opentracing.TextMapCarrier{
+				"ct-bag-tenant":   "t1",
+				"ct-bag-internal": "x",
+				"ct-bag-evil":     "y",
+				"ct-bag-bogus":    "z",
+			})
+			Ω(c.BaggageItem("tenant")).Should(Equal("t1"))
+			Ω(c.BaggageItem("internal")).Should(BeEmpty())
+			Ω(c.BaggageItem("evil")).Should(BeEmpty())
+
+			trc.StartSpan("op", opentracing.ChildOf(c)).Finish()
+			logs := droppedLogs()
+			Ω(logs).Should(HaveLen(2))
+			Ω(logs[0]).Should(HaveKeyWithValue("reason", "not allowed"))
+			Ω(logs[0]).Should(HaveKeyWithValue("keys", "bogus,evil"))
+			Ω(logs[1]).Should(HaveKeyWithValue("reason", "not allowed inbound"))
+			Ω(logs[1]).Should(HaveKeyWithValue("keys", "internal"))
+		})
+
+		It("refuses other keys on SetBaggageItem", func() {
+			sp := trc.StartSpan("op")
+			sp.SetBaggageItem("internal", "x")
+			sp.SetBaggageItem("evil", "y")
+			Ω(sp.BaggageItem("internal")).Should(Equal("x"))
+			Ω(sp.BaggageItem("evil")).Should(BeEmpty())
+			sp.Finish()
+
+			logs := droppedLogs()
+			Ω(logs).Should(HaveLen(1))
+			Ω(logs[0]).Should(HaveKeyWithValue("keys", "evil"))
+		})
+	})
+
+	Context("with limits", func() {
+		BeforeEach(func() {
+			policy = core.BaggagePolicy{MaxItems: 2, MaxBytes: 10}
+		})
+
+		It("keeps items in key order", func() {
+			c := extract(opentracing.TextMapCarrier{
+				"ct-bag-a": "1",
+				"ct-bag-b": "2",
+				"ct-bag-c": "3",
+			})
+			Ω(c.BaggageItem("a")).Should(Equal("1"))
+			Ω(c.BaggageItem("b")).Should(Equal("2"))
+			Ω(c.BaggageItem("c")).Should(BeEmpty())
+		})
+
+		It("limits bytes", func() {
+			c := extract(opentracing.TextMapCarrier{
+				"ct-bag-a": "12345",
+				"ct-bag-b": "67890",
+			})
+			Ω(c.BaggageItem("a")).Should(Equal("12345"))
+			Ω(c.BaggageItem("b")).Should(BeEmpty())
+
+			trc.StartSpan("op", opentracing.ChildOf(c)).Finish()
+			Ω(droppedLogs()[0]).Should(HaveKeyWithValue("reason", "max bytes"))
+		})
+	})
+
+	Context("with outbound keys", func() {
+		BeforeEach(func() {
+			policy = core.BaggagePolicy{OutboundKeys: []string{"tenant"}}
+		})
+
+		It("withholds other keys from outgoing requests", func() {
+			sp := trc.StartSpan("op")
+			sp.SetBaggageItem("tenant", "t1")
+			sp.SetBaggageItem("secret", "s")
+			Ω(sp.BaggageItem("secret")).Should(Equal("s"))
+
+			txt := opentracing.TextMapCarrier{}
+			Ω(trc.Inject(sp.Context(), core.TextMap, txt)).Should(Succeed())
+			Ω(txt).Should(HaveKeyWithValue("ct-bag-tenant", "t1"))
+			Ω(txt).ShouldNot(HaveKey("ct-bag-secret"))
+			sp.Finish()
+			Ω(droppedLogs()).Should(BeEmpty())
+		})
+
+		It("logs withheld keys on the injecting span", func() {
+			sp := trc.StartSpan("op")
+			sp.SetBaggageItem("tenant", "t1")
+			sp.SetBaggageItem("secret", "s")
+
+			txt := opentracing.TextMapCarrier{}
+			Ω(trc.InjectSpan(sp, core.TextMap, txt)).Should(Succeed())
+			Ω(txt).Should(HaveKeyWithValue("ct-bag-tenant", "t1"))
+			Ω(txt).ShouldNot(HaveKey("ct-bag-secret"))
+			sp.Finish()
+
+			logs := droppedLogs()
+			Ω(logs).Should(HaveLen(1))
+			Ω(logs[0]).Should(HaveKeyWithValue("reason", "not allowed outbound"))
+			Ω(logs[0]).Should(HaveKeyWithValue("keys", "secret"))
+		})
+	})
+})
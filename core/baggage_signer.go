@@ -0,0 +1,121 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	// BaggageSignatureItem is the baggage item carrying the signatures of the
+	// other items when TracerOptions.BaggageSigningKeys are set.
+	BaggageSignatureItem = "ct-baggage-sig"
+
+	// Signatures are truncated HMAC-SHA256 sums, which are plenty to detect
+	// tampering and keep the headers short.
+	baggageSignatureLength = 16
+)
+
+// baggageSigner signs and verifies baggage items using HMAC-SHA256.  Each item
+// is signed separately, so one bad item does not invalidate the others, and
+// its signature is bound to the trace ID, so signed items cannot be replayed
+// in other traces.
+type baggageSigner struct {
+	keys [][]byte
+}
+
+// newBaggageSigner returns a signer signing with the first key and verifying
+// with all of them, or nil if there are no keys.
+func newBaggageSigner(keys [][]byte) *baggageSigner {
+	if len(keys) == 0 {
+		return nil
+	}
+	return &baggageSigner{keys: keys}
+}
+
+func (s *baggageSigner) signature(key []byte, traceID TraceID, k, v string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(traceID.appendHex(make([]byte, 0, 32)))
+	// Keys are signed in lower case as the HTTPHeaders format does not
+	// preserve their case.
+	mac.Write([]byte{0})
+	mac.Write([]byte(strings.ToLower(k)))
+	mac.Write([]byte{0})
+	mac.Write([]byte(v))
+	return mac.Sum(nil)[:baggageSignatureLength]
+}
+
+// sign returns a copy of the baggage of sc with the BaggageSignatureItem
+// added.
+func (s *baggageSigner) sign(sc spanContext) map[string]string {
+	if len(sc.baggage) == 0 {
+		return sc.baggage
+	}
+	signed := make(map[string]string, len(sc.baggage)+1)
+	sigs := make([]string, 0, len(sc.baggage))
+	for k, v := range sc.baggage {
+		if k == BaggageSignatureItem {
+			continue
+		}
+		signed[k] = v
+		sig := base64.RawURLEncoding.EncodeToString(s.signature(s.keys[0], sc.traceID, k, v))
+		sigs = append(sigs, url.QueryEscape(strings.ToLower(k))+"="+sig)
+	}
+	sort.Strings(sigs)
+	signed[BaggageSignatureItem] = strings.Join(sigs, ",")
+	return signed
+}
+
+// verify returns the baggage of sc whose signatures are valid with any of the
+// keys, without the BaggageSignatureItem, and the items dropped.
+func (s *baggageSigner) verify(sc spanContext) (map[string]string, baggageViolations) {
+	if len(sc.baggage) == 0 {
+		return sc.baggage, nil
+	}
+	sigs := make(map[string][]byte)
+	for _, item := range strings.Split(sc.baggage[BaggageSignatureItem], ",") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		k, err := url.QueryUnescape(kv[0])
+		if err != nil {
+			continue
+		}
+		if sig, err := base64.RawURLEncoding.DecodeString(kv[1]); err == nil {
+			sigs[k] = sig
+		}
+	}
+
+	var violations baggageViolations
+	verified := make(map[string]string, len(sc.baggage))
+	for k, v := range sc.baggage {
+		if k == BaggageSignatureItem {
+			continue
+		}
+		if s.valid(sigs[strings.ToLower(k)], sc.traceID, k, v) {
+			verified[k] = v
+			continue
+		}
+		if violations == nil {
+			violations = make(baggageViolations)
+		}
+		violations[baggageInvalidSignature] = append(violations[baggageInvalidSignature], k)
+	}
+	return verified, violations
+}
+
+func (s *baggageSigner) valid(sig []byte, traceID TraceID, k, v string) bool {
+	if len(sig) != baggageSignatureLength {
+		return false
+	}
+	for _, key := range s.keys {
+		if hmac.Equal(sig, s.signature(key, traceID, k, v)) {
+			return true
+		}
+	}
+	return false
+}
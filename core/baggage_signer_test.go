@@ -0,0 +1,93 @@
+package core_test
+
+import (
+	"github.com/Nordstrom/ctrace-go/core"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+var _ = Describe("BaggageSigningKeys", func() {
+	var (
+		buf    core.Buffer
+		signer core.Tracer
+		ctx    core.SpanContext
+	)
+
+	tracer := func(keys ...string) core.Tracer {
+		var bkeys [][]byte
+		for _, k := range keys {
+			bkeys = append(bkeys, []byte(k))
+		}
+		return core.NewWithOptions(core.TracerOptions{
+			Writer:             &buf,
+			MultiEvent:         true,
+			BaggageSigningKeys: bkeys,
+		})
+	}
+
+	inject := func(t core.Tracer, c opentracing.SpanContext) opentracing.TextMapCarrier {
+		txt := opentracing.TextMapCarrier{}
+		Ω(t.Inject(c, core.TextMap, txt)).Should(Succeed())
+		return txt
+	}
+
+	extract := func(t core.Tracer, txt opentracing.TextMapCarrier) core.SpanContext {
+		c, err := t.Extract(core.TextMap, txt)
+		Ω(err).ShouldNot(HaveOccurred())
+		return c.(core.SpanContext)
+	}
+
+	BeforeEach(func() {
+		buf.Reset()
+		signer = tracer("old", "new")
+		ctx = core.NewSpanContext(123, 456, map[string]string{"tenant": "t1", "role": "user"})
+	})
+
+	It("signs injected baggage", func() {
+		txt := inject(signer, ctx)
+		Ω(txt).Should(HaveKeyWithValue("ct-bag-tenant", "t1"))
+		Ω(txt).Should(HaveKey("ct-bag-" + core.BaggageSignatureItem))
+	})
+
+	It("verifies extracted baggage", func() {
+		c := extract(tracer("new", "old"), inject(signer, ctx))
+		Ω(c.BaggageItem("tenant")).Should(Equal("t1"))
+		Ω(c.BaggageItem("role")).Should(Equal("user"))
+		Ω(c.BaggageItem(core.BaggageSignatureItem)).Should(BeEmpty())
+	})
+
+	It("verifies HTTP Headers", func() {
+		hdrs := opentracing.HTTPHeadersCarrier{}
+		Ω(signer.Inject(ctx, core.HTTPHeaders, hdrs)).Should(Succeed())
+		c, err := signer.Extract(core.HTTPHeaders, hdrs)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(c.(core.SpanContext).BaggageItem("tenant")).Should(Equal("t1"))
+	})
+
+	It("drops tampered, added, and replayed items and logs them", func() {
+		txt := inject(signer, ctx)
+		txt["ct-bag-role"] = "admin"
+		txt["ct-bag-extra"] = "x"
+		c := extract(signer, txt)
+		Ω(c.BaggageItem("tenant")).Should(Equal("t1"))
+		Ω(c.BaggageItem("role")).Should(BeEmpty())
+		Ω(c.BaggageItem("extra")).Should(BeEmpty())
+
+		txt = inject(signer, ctx)
+		txt["ct-trace-id"] = "7c"
+		Ω(extract(signer, txt).BaggageItem("tenant")).Should(BeEmpty())
+
+		signer.StartSpan("op", opentracing.ChildOf(c)).Finish()
+		spans := buf.Spans()
+		Ω(spans[1].Logs[0]).Should(HaveKeyWithValue("event", "Baggage-Dropped"))
+		Ω(spans[1].Logs[0]).Should(HaveKeyWithValue("reason", "invalid signature"))
+		Ω(spans[1].Logs[0]).Should(HaveKeyWithValue("keys", SatisfyAny(Equal("extra,role"), Equal("role,extra"))))
+	})
+
+	It("rejects items signed with unknown keys", func() {
+		c := extract(signer, inject(tracer("other"), ctx))
+		Ω(c.BaggageItem("tenant")).Should(BeEmpty())
+	})
+})
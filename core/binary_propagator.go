@@ -0,0 +1,230 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"sort"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// The binary encoding of a spanContext is
+//
+//	version       1 byte, binaryVersion
+//	flags         1 byte, see binaryFlag*
+//	trace ID high 8 bytes big-endian, only if binaryFlag128Bit is set
+//	trace ID low  8 bytes big-endian
+//	span ID       8 bytes big-endian
+//	baggage count uvarint
+//	baggage items uvarint key length, key, uvarint value length, value
+//
+// Decoders reject versions they do not know, so a new version must be added
+// whenever the layout changes.
+const (
+	binaryVersion byte = 1
+
+	binaryFlagSampled byte = 1 << 0
+	binaryFlagDebug   byte = 1 << 1
+	binaryFlag128Bit  byte = 1 << 2
+
+	// Guards against allocating huge amounts of memory for corrupted input.
+	maxBinaryBaggageItems = 1 << 12
+	maxBinaryStringLength = 1 << 16
+)
+
+type binaryPropagator struct{}
+
+func (p *binaryPropagator) Inject(
+	ctx opentracing.SpanContext,
+	opaqueCarrier interface{},
+) error {
+	sc, ok := ctx.(spanContext)
+	if !ok {
+		return opentracing.ErrInvalidSpanContext
+	}
+	switch carrier := opaqueCarrier.(type) {
+	case *[]byte:
+		if carrier == nil {
+			return opentracing.ErrInvalidCarrier
+		}
+		*carrier = appendBinary((*carrier)[:0], sc)
+		return nil
+	case io.Writer:
+		_, err := carrier.Write(appendBinary(make([]byte, 0, 64), sc))
+		return err
+	}
+	return opentracing.ErrInvalidCarrier
+}
+
+func (p *binaryPropagator) Extract(
+	opaqueCarrier interface{},
+) (opentracing.SpanContext, error) {
+	switch carrier := opaqueCarrier.(type) {
+	case *[]byte:
+		if carrier == nil {
+			return nil, opentracing.ErrInvalidCarrier
+		}
+		return readBinary(bytes.NewReader(*carrier))
+	case []byte:
+		return readBinary(bytes.NewReader(carrier))
+	case io.Reader:
+		return readBinary(carrier)
+	}
+	return nil, opentracing.ErrInvalidCarrier
+}
+
+func appendBinary(b []byte, sc spanContext) []byte {
+	var flags byte
+	if sc.sampled {
+		flags |= binaryFlagSampled
+	}
+	if sc.debug {
+		flags |= binaryFlagDebug
+	}
+	if sc.traceID.Is128Bit() {
+		flags |= binaryFlag128Bit
+	}
+	b = append(b, binaryVersion, flags)
+
+	var buf [binary.MaxVarintLen64]byte
+	if sc.traceID.Is128Bit() {
+		binary.BigEndian.PutUint64(buf[:], sc.traceID.High)
+		b = append(b, buf[:8]...)
+	}
+	binary.BigEndian.PutUint64(buf[:], sc.traceID.Low)
+	b = append(b, buf[:8]...)
+	binary.BigEndian.PutUint64(buf[:], sc.spanID)
+	b = append(b, buf[:8]...)
+
+	// Sort the baggage so equal contexts have equal encodings.
+	keys := make([]string, 0, len(sc.baggage))
+	for k := range sc.baggage {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b = append(b, buf[:binary.PutUvarint(buf[:], uint64(len(keys)))]...)
+	for _, k := range keys {
+		for _, s := range []string{k, sc.baggage[k]} {
+			b = append(b, buf[:binary.PutUvarint(buf[:], uint64(len(s)))]...)
+			b = append(b, s...)
+		}
+	}
+	return b
+}
+
+func readBinary(r io.Reader) (opentracing.SpanContext, error) {
+	br := byteReader{r}
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return nil, opentracing.ErrSpanContextNotFound
+		}
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	if header[0] != binaryVersion {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	flags := header[1]
+
+	sc := spanContext{
+		sampled: flags&binaryFlagSampled != 0,
+		debug:   flags&binaryFlagDebug != 0,
+	}
+	ids := make([]byte, 16, 24)
+	if flags&binaryFlag128Bit != 0 {
+		ids = ids[:24]
+	}
+	if _, err := io.ReadFull(r, ids); err != nil {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	if flags&binaryFlag128Bit != 0 {
+		sc.traceID.High = binary.BigEndian.Uint64(ids)
+		ids = ids[8:]
+	}
+	sc.traceID.Low = binary.BigEndian.Uint64(ids)
+	sc.spanID = binary.BigEndian.Uint64(ids[8:])
+
+	count, err := binary.ReadUvarint(br)
+	if err != nil || count > maxBinaryBaggageItems {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	if count > 0 {
+		sc.baggage = make(map[string]string, count)
+	}
+	for i := uint64(0); i < count; i++ {
+		k, err := readBinaryString(r, br)
+		if err != nil {
+			return nil, err
+		}
+		v, err := readBinaryString(r, br)
+		if err != nil {
+			return nil, err
+		}
+		sc.baggage[k] = v
+	}
+
+	if !sc.traceID.IsValid() && !sc.debug {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	return sc, nil
+}
+
+func readBinaryString(r io.Reader, br io.ByteReader) (string, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil || n > maxBinaryStringLength {
+		return "", opentracing.ErrSpanContextCorrupted
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", opentracing.ErrSpanContextCorrupted
+	}
+	return string(b), nil
+}
+
+// byteReader reads single bytes without reading ahead, so the carrier is left
+// positioned right after the encoded context.
+type byteReader struct {
+	io.Reader
+}
+
+func (r byteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	_, err := io.ReadFull(r.Reader, b[:])
+	return b[0], err
+}
+
+// ContextToken encodes a SpanContext as a URL-safe string, which can be stored,
+// e.g. in a database row, to continue the trace later using
+// ParseContextToken.  The context is injected by tracer in the Binary format,
+// so its outbound BaggagePolicy and baggage signing apply.
+func ContextToken(tracer opentracing.Tracer, ctx opentracing.SpanContext) (string, error) {
+	var b []byte
+	if err := tracer.Inject(ctx, opentracing.Binary, &b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ParseContextToken decodes a SpanContext encoded by ContextToken.  The
+// context is extracted by tracer in the Binary format, so its inbound
+// BaggagePolicy and baggage verification apply.
+func ParseContextToken(tracer opentracing.Tracer, token string) (SpanContext, error) {
+	if token == "" {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	ctx, err := tracer.Extract(opentracing.Binary, b)
+	if err != nil {
+		return nil, err
+	}
+	sc, ok := ctx.(SpanContext)
+	if !ok {
+		return nil, opentracing.ErrInvalidSpanContext
+	}
+	return sc, nil
+}
@@ -0,0 +1,134 @@
+package core_test
+
+import (
+	"bytes"
+
+	"github.com/Nordstrom/ctrace-go/core"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+var _ = Describe("Binary Propagation", func() {
+	var (
+		trc core.Tracer
+		ctx core.SpanContext
+	)
+
+	BeforeEach(func() {
+		trc = core.New()
+		ctx = core.NewSpanContextFromTraceID(
+			core.TraceID{High: 1, Low: 2}, 3, map[string]string{"k1": "v1", "k2": "v2"})
+	})
+
+	extracted := func(c opentracing.SpanContext, err error) core.SpanContext {
+		Ω(err).ShouldNot(HaveOccurred())
+		return c.(core.SpanContext)
+	}
+
+	It("round-trips through io.Writer and io.Reader", func() {
+		var buf bytes.Buffer
+		Ω(trc.Inject(ctx, core.Binary, &buf)).Should(Succeed())
+		buf.WriteString("rest")
+
+		c := extracted(trc.Extract(core.Binary, &buf))
+		Ω(c.RawTraceID()).Should(Equal(core.TraceID{High: 1, Low: 2}))
+		Ω(c.RawSpanID()).Should(Equal(uint64(3)))
+		Ω(c.IsSampled()).Should(BeTrue())
+		Ω(c.IsDebug()).Should(BeFalse())
+		Ω(c.BaggageItem("k1")).Should(Equal("v1"))
+		Ω(c.BaggageItem("k2")).Should(Equal("v2"))
+		Ω(buf.String()).Should(Equal("rest"))
+	})
+
+	It("round-trips through *[]byte", func() {
+		var b []byte
+		Ω(trc.Inject(ctx, core.Binary, &b)).Should(Succeed())
+		Ω(b[0]).Should(Equal(byte(1)))
+
+		c := extracted(trc.Extract(core.Binary, &b))
+		Ω(c.TraceID()).Should(Equal("00000000000000010000000000000002"))
+	})
+
+	It("round-trips flags", func() {
+		hdrs := opentracing.TextMapCarrier{"ct-trace-id": "7b", "ct-span-id": "1c8", "ct-sampled": "0"}
+		unsampled := extracted(trc.Extract(core.TextMap, hdrs))
+		var b []byte
+		Ω(trc.Inject(unsampled, core.Binary, &b)).Should(Succeed())
+		Ω(extracted(trc.Extract(core.Binary, b)).IsSampled()).Should(BeFalse())
+
+		debug := extracted(trc.Extract(core.TextMap, opentracing.TextMapCarrier{"ct-debug": "1"}))
+		Ω(trc.Inject(debug, core.Binary, &b)).Should(Succeed())
+		c := extracted(trc.Extract(core.Binary, b))
+		Ω(c.IsDebug()).Should(BeTrue())
+		Ω(c.IsSampled()).Should(BeTrue())
+	})
+
+	It("rejects unknown versions", func() {
+		var b []byte
+		Ω(trc.Inject(ctx, core.Binary, &b)).Should(Succeed())
+		b[0] = 2
+		_, err := trc.Extract(core.Binary, b)
+		Ω(err).Should(Equal(opentracing.ErrSpanContextCorrupted))
+	})
+
+	It("rejects truncated input", func() {
+		var b []byte
+		Ω(trc.Inject(ctx, core.Binary, &b)).Should(Succeed())
+		_, err := trc.Extract(core.Binary, b[:len(b)-1])
+		Ω(err).Should(Equal(opentracing.ErrSpanContextCorrupted))
+	})
+
+	It("returns not found for empty input", func() {
+		_, err := trc.Extract(core.Binary, []byte{})
+		Ω(err).Should(Equal(opentracing.ErrSpanContextNotFound))
+	})
+
+	It("rejects invalid carriers", func() {
+		Ω(trc.Inject(ctx, core.Binary, "")).Should(Equal(opentracing.ErrInvalidCarrier))
+		_, err := trc.Extract(core.Binary, "")
+		Ω(err).Should(Equal(opentracing.ErrInvalidCarrier))
+
+		var nilBytes *[]byte
+		Ω(trc.Inject(ctx, core.Binary, nilBytes)).Should(Equal(opentracing.ErrInvalidCarrier))
+		_, err = trc.Extract(core.Binary, nilBytes)
+		Ω(err).Should(Equal(opentracing.ErrInvalidCarrier))
+	})
+
+	Describe("ContextToken", func() {
+		It("round-trips", func() {
+			token, err := core.ContextToken(trc, ctx)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(token).Should(MatchRegexp(`^[A-Za-z0-9_-]+$`))
+
+			c, err := core.ParseContextToken(trc, token)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(c).Should(Equal(ctx))
+		})
+
+		It("rejects corrupted tokens", func() {
+			_, err := core.ParseContextToken(trc, "!!")
+			Ω(err).Should(Equal(opentracing.ErrSpanContextCorrupted))
+		})
+
+		It("applies the baggage policy and signing", func() {
+			signed := core.NewWithOptions(core.TracerOptions{
+				BaggagePolicy:      core.BaggagePolicy{InboundKeys: []string{"k1"}},
+				BaggageSigningKeys: [][]byte{[]byte("secret")},
+			})
+			token, err := core.ContextToken(signed, ctx)
+			Ω(err).ShouldNot(HaveOccurred())
+			c, err := core.ParseContextToken(signed, token)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(c.BaggageItem("k1")).Should(Equal("v1"))
+			Ω(c.BaggageItem("k2")).Should(BeEmpty())
+
+			unsigned, err := core.ContextToken(trc, ctx)
+			Ω(err).ShouldNot(HaveOccurred())
+			c, err = core.ParseContextToken(signed, unsigned)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(c.BaggageItem("k1")).Should(BeEmpty())
+		})
+	})
+})
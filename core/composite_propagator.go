@@ -0,0 +1,73 @@
+package core
+
+import (
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+type compositePropagator struct {
+	propagators []Propagator
+}
+
+// NewCompositePropagator creates a Propagator combining several formats, e.g.
+// to accept ct-*, W3C, and B3 headers during a migration.  Inject injects
+// the SpanContext using every Propagator.  Extract tries the Propagators in
+// order and returns the first SpanContext found.
+func NewCompositePropagator(propagators ...Propagator) Propagator {
+	return &compositePropagator{
+		propagators: append([]Propagator(nil), propagators...),
+	}
+}
+
+// Inject returns the first error of any of the Propagators, after all of them
+// have been tried.
+func (p *compositePropagator) Inject(
+	ctx opentracing.SpanContext,
+	carrier interface{},
+) error {
+	var firstErr error
+	for _, prop := range p.propagators {
+		if err := prop.Inject(ctx, carrier); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Extract skips Propagators that find no SpanContext or a corrupted one.  A
+// debug request without a trace ID is remembered, and turns on debugging for
+// a trace found by a later Propagator.  If none of them finds a SpanContext,
+// it returns the first error other than opentracing.ErrSpanContextNotFound,
+// if any.
+func (p *compositePropagator) Extract(
+	carrier interface{},
+) (opentracing.SpanContext, error) {
+	var firstErr error
+	var debugOnly opentracing.SpanContext
+	for _, prop := range p.propagators {
+		ctx, err := prop.Extract(carrier)
+		if err != nil {
+			if err != opentracing.ErrSpanContextNotFound && firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		sc, ok := ctx.(spanContext)
+		if !ok || sc.traceID.IsValid() {
+			if debugOnly != nil && ok {
+				sc.sampled, sc.debug = true, true
+				return sc, nil
+			}
+			return ctx, nil
+		}
+		if debugOnly == nil {
+			debugOnly = sc
+		}
+	}
+	if debugOnly != nil {
+		return debugOnly, nil
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return nil, opentracing.ErrSpanContextNotFound
+}
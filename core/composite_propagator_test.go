@@ -0,0 +1,104 @@
+package core_test
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Nordstrom/ctrace-go/core"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// failingPropagator returns err from Inject and Extract.
+type failingPropagator struct {
+	err error
+}
+
+func (p failingPropagator) Inject(opentracing.SpanContext, interface{}) error {
+	return p.err
+}
+
+func (p failingPropagator) Extract(interface{}) (opentracing.SpanContext, error) {
+	return nil, p.err
+}
+
+var _ = Describe("CompositePropagator", func() {
+	var (
+		p   core.Propagator
+		ctx core.SpanContext
+	)
+
+	BeforeEach(func() {
+		p = core.NewCompositePropagator(
+			core.NewHTTPHeadersPropagator(),
+			core.NewW3CPropagator(),
+			core.NewB3Propagator(),
+		)
+		ctx = core.NewSpanContext(1, 2, map[string]string{"k": "v"})
+	})
+
+	extract := func(h http.Header) (core.SpanContext, error) {
+		c, err := p.Extract(opentracing.HTTPHeadersCarrier(h))
+		if err != nil {
+			return nil, err
+		}
+		return c.(core.SpanContext), nil
+	}
+
+	It("injects every format", func() {
+		h := http.Header{}
+		Ω(p.Inject(ctx, opentracing.HTTPHeadersCarrier(h))).Should(Succeed())
+		Ω(h.Get("ct-trace-id")).Should(Equal("0000000000000001"))
+		Ω(h.Get("traceparent")).Should(Equal("00-00000000000000000000000000000001-0000000000000002-01"))
+		Ω(h.Get("x-b3-traceid")).Should(Equal("0000000000000001"))
+	})
+
+	It("extracts the first format found", func() {
+		h := http.Header{}
+		h.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+		h.Set("x-b3-traceid", "a3ce929d0e0e4736")
+		h.Set("x-b3-spanid", "a2fb4a1d1a96d312")
+		c, err := extract(h)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(c.TraceID()).Should(Equal("0af7651916cd43dd8448eb211c80319c"))
+	})
+
+	It("skips corrupted formats", func() {
+		h := http.Header{}
+		h.Set("ct-trace-id", "xyz")
+		h.Set("x-b3-traceid", "a3ce929d0e0e4736")
+		h.Set("x-b3-spanid", "a2fb4a1d1a96d312")
+		c, err := extract(h)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(c.TraceID()).Should(Equal("a3ce929d0e0e4736"))
+	})
+
+	It("returns the first error", func() {
+		h := http.Header{}
+		h.Set("ct-trace-id", "xyz")
+		_, err := extract(h)
+		Ω(err).Should(Equal(opentracing.ErrSpanContextCorrupted))
+
+		_, err = extract(http.Header{})
+		Ω(err).Should(Equal(opentracing.ErrSpanContextNotFound))
+
+		failed := errors.New("failed")
+		p = core.NewCompositePropagator(failingPropagator{failed}, core.NewW3CPropagator())
+		h = http.Header{}
+		Ω(p.Inject(ctx, opentracing.HTTPHeadersCarrier(h))).Should(Equal(failed))
+		Ω(h.Get("traceparent")).ShouldNot(BeEmpty())
+	})
+
+	It("debugs traces found by later formats", func() {
+		h := http.Header{}
+		h.Set("ct-debug", "1")
+		h.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00")
+		c, err := extract(h)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(c.TraceID()).Should(Equal("0af7651916cd43dd8448eb211c80319c"))
+		Ω(c.IsDebug()).Should(BeTrue())
+		Ω(c.IsSampled()).Should(BeTrue())
+	})
+})
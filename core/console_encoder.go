@@ -0,0 +1,296 @@
+package core
+
+import (
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ANSI escape sequences used by the console encoder.
+const (
+	ansiReset = "\x1b[0m"
+	ansiDim   = "\x1b[2m"
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiBlue  = "\x1b[34m"
+	ansiBold  = "\x1b[1m"
+
+	consoleEventWidth = 12
+
+	// maxConsoleSpans bounds the Spans whose depth is remembered, so Spans
+	// which never finish do not leak.
+	maxConsoleSpans = 10000
+)
+
+// traceColors tell the traces of interleaved events apart.
+var traceColors = []string{
+	"\x1b[36m", "\x1b[35m", "\x1b[33m", "\x1b[32m", "\x1b[34m", "\x1b[91m",
+}
+
+// ConsoleEncoderOptions allows customizing a SpanEncoder created via
+// NewConsoleEncoder.
+type ConsoleEncoderOptions struct {
+	// Color highlights events, trace IDs, and errors with ANSI colors.  Use
+	// IsTerminal to only enable it when writing to a terminal.
+	Color bool
+}
+
+// consoleEncoder is a SpanEncoder writing human-readable lines in logfmt.
+type consoleEncoder struct {
+	jsonEncoder
+	opts ConsoleEncoderOptions
+
+	sync.Mutex
+	depths map[uint64]int
+}
+
+// NewConsoleEncoder creates a SpanEncoder for reading traces during local
+// development.  Each event is a line with aligned columns for the time, the
+// last 8 hex digits of the trace and span IDs, and the event, followed by the
+// operation indented by its depth in the trace, and the tags, log fields, and
+// baggage in logfmt, e.g.
+//
+//	15:04:05.000 5eca9d1b 5eca9d1b Start-Span  GET /users
+//	15:04:05.001 5eca9d1b 0d7c6e2a Start-Span    db.query db.type=sql
+//	15:04:05.004 5eca9d1b 0d7c6e2a Finish-Span   db.query duration=3.1ms db.type=sql
+//
+// Spans are indented below the Spans they were started from if the
+// Start-Span event of the parent was encoded before, so the tree is best seen
+// in Multi-Event Mode.  The format is meant for people and may change; use
+// the JSON SpanEncoder for anything parsing trace events.
+func NewConsoleEncoder(opts ConsoleEncoderOptions) SpanEncoder {
+	return &consoleEncoder{opts: opts, depths: make(map[uint64]int)}
+}
+
+// IsTerminal tells whether w is a terminal, e.g. to decide whether to set
+// ConsoleEncoderOptions.Color.
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func (enc *consoleEncoder) Encode(sp *SpanData) []byte {
+	bytes := make([]byte, 0, 256)
+	depth := enc.depth(sp)
+	for _, lr := range sp.logs {
+		if lr.Timestamp.IsZero() {
+			continue
+		}
+		fields := expandLazyFields(lr.Fields)
+		event := "Log"
+		for _, f := range fields {
+			if f.Key() == "event" {
+				if s, ok := f.Value().(string); ok {
+					event = s
+				}
+				break
+			}
+		}
+
+		bytes = enc.encodeColumns(bytes, sp, lr.Timestamp, event)
+		bytes = append(bytes, strings.Repeat("  ", depth)...)
+		bytes = appendConsoleText(bytes, sp.operation)
+		switch event {
+		case "Start-Span":
+			if sp.duration < 0 {
+				bytes = enc.encodeTags(bytes, sp)
+			}
+		case "Finish-Span":
+			bytes = enc.encodePair(bytes, "duration", sp.duration.String())
+			bytes = enc.encodeTags(bytes, sp)
+		default:
+			for _, f := range fields {
+				if f.Key() != "event" {
+					bytes = enc.encodePair(bytes, f.Key(), f.Value())
+				}
+			}
+		}
+		bytes = append(bytes, '\n')
+	}
+	return bytes
+}
+
+// depth returns the depth of the Span in its trace as far as it is known, and
+// remembers it until the Span finishes.
+func (enc *consoleEncoder) depth(sp *SpanData) int {
+	id := sp.context.spanID
+	enc.Lock()
+	defer enc.Unlock()
+	depth, ok := enc.depths[id]
+	if !ok && sp.parentID != 0 {
+		if parent, ok := enc.depths[sp.parentID]; ok {
+			depth = parent + 1
+		}
+	}
+	if sp.duration >= 0 {
+		delete(enc.depths, id)
+	} else if !ok {
+		if len(enc.depths) >= maxConsoleSpans {
+			enc.depths = make(map[uint64]int)
+		}
+		enc.depths[id] = depth
+	}
+	return depth
+}
+
+func (enc *consoleEncoder) encodeColumns(
+	bytes []byte,
+	sp *SpanData,
+	ts time.Time,
+	event string,
+) []byte {
+	traceID := sp.context.traceID.Low
+	bytes = enc.color(bytes, ansiDim)
+	bytes = ts.AppendFormat(bytes, "15:04:05.000")
+	bytes = enc.color(bytes, ansiReset)
+	bytes = append(bytes, ' ')
+	bytes = enc.color(bytes, traceColors[traceID%uint64(len(traceColors))])
+	bytes = appendShortID(bytes, traceID)
+	bytes = enc.color(bytes, ansiReset)
+	bytes = append(bytes, ' ')
+	bytes = appendShortID(bytes, sp.context.spanID)
+	bytes = append(bytes, ' ')
+
+	switch {
+	case event == "error" || (event == "Finish-Span" && isError(sp.tags["error"])):
+		bytes = enc.color(bytes, ansiRed+ansiBold)
+	case event == "Start-Span":
+		bytes = enc.color(bytes, ansiGreen)
+	case event == "Finish-Span":
+		bytes = enc.color(bytes, ansiBlue)
+	}
+	start := len(bytes)
+	bytes = appendConsoleText(bytes, event)
+	n := utf8.RuneCount(bytes[start:])
+	bytes = enc.color(bytes, ansiReset)
+	for ; n < consoleEventWidth; n++ {
+		bytes = append(bytes, ' ')
+	}
+	return bytes
+}
+
+// encodeTags appends the dropped counts, tags, and baggage of sp in sorted
+// order.
+func (enc *consoleEncoder) encodeTags(bytes []byte, sp *SpanData) []byte {
+	if sp.droppedTags > 0 {
+		bytes = enc.encodePair(bytes, "droppedTags", sp.droppedTags)
+	}
+	if sp.droppedLogs > 0 {
+		bytes = enc.encodePair(bytes, "droppedLogs", sp.droppedLogs)
+	}
+	if sp.droppedBaggage > 0 {
+		bytes = enc.encodePair(bytes, "droppedBaggage", sp.droppedBaggage)
+	}
+
+	keys := make([]string, 0, len(sp.tags))
+	for k := range sp.tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		bytes = enc.encodePair(bytes, k, sp.tags[k])
+	}
+
+	keys = keys[:0]
+	for k := range sp.context.baggage {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		bytes = enc.encodePair(bytes, "baggage."+k, sp.context.baggage[k])
+	}
+	return bytes
+}
+
+// encodePair appends a space and key=value.  Strings are quoted if they would
+// otherwise be ambiguous, and other values are encoded as JSON.
+func (enc *consoleEncoder) encodePair(bytes []byte, key string, v interface{}) []byte {
+	bytes = append(bytes, ' ')
+	bytes = enc.color(bytes, ansiDim)
+	bytes = appendLogfmt(bytes, key)
+	bytes = append(bytes, '=')
+	bytes = enc.color(bytes, ansiReset)
+	if s, ok := v.(string); ok {
+		return appendLogfmt(bytes, s)
+	}
+	return enc.encodeValue(bytes, v, 0, maxValueSize)
+}
+
+func (enc *consoleEncoder) color(bytes []byte, code string) []byte {
+	if enc.opts.Color {
+		bytes = append(bytes, code...)
+	}
+	return bytes
+}
+
+// appendLogfmt appends s, quoted if it is empty or has spaces, quotes, '=',
+// control characters, or invalid UTF-8.
+func appendLogfmt(bytes []byte, s string) []byte {
+	if s == "" {
+		return append(bytes, `""`...)
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError ||
+			r == 0x7f || r == 0x85 || r == 0xa0 {
+			return strconv.AppendQuote(bytes, s)
+		}
+	}
+	return append(bytes, s...)
+}
+
+// appendConsoleText appends s with its control and other non-printable
+// characters, and invalid UTF-8, escaped as in Go strings, so that it cannot
+// break lines or inject terminal escape sequences.
+func appendConsoleText(bytes []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			bytes = append(bytes, '\\', 'x', hex[s[i]>>4], hex[s[i]&15])
+		case r != ' ' && !strconv.IsPrint(r):
+			q := strconv.QuoteRuneToASCII(r)
+			bytes = append(bytes, q[1:len(q)-1]...)
+		default:
+			bytes = append(bytes, s[i:i+size]...)
+		}
+		i += size
+	}
+	return bytes
+}
+
+// appendShortID appends the last 8 hex digits of id, which tell the Spans of
+// a trace apart well enough to read it.
+func appendShortID(bytes []byte, id uint64) []byte {
+	var b [16]byte
+	return append(bytes, appendHexID(b[:0], id)[8:]...)
+}
+
+func isError(v interface{}) bool {
+	b, ok := v.(bool)
+	return ok && b
+}
+
+// newEnvEncoder returns the SpanEncoder chosen by environment variable
+// "CTRACE_FORMAT" for writing to w.  Colors can be turned off with "NO_COLOR".
+func newEnvEncoder(w io.Writer) SpanEncoder {
+	switch strings.ToLower(os.Getenv("CTRACE_FORMAT")) {
+	case "console", "logfmt":
+		return NewConsoleEncoder(ConsoleEncoderOptions{
+			Color: IsTerminal(w) && os.Getenv("NO_COLOR") == "",
+		})
+	case "protobuf":
+		return NewProtobufSpanEncoder()
+	default:
+		return NewSpanEncoder()
+	}
+}
@@ -0,0 +1,181 @@
+package core
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+var _ = Describe("ConsoleEncoder", func() {
+
+	var (
+		enc   SpanEncoder
+		start time.Time
+	)
+
+	event := func(ts time.Time, fields ...log.Field) []opentracing.LogRecord {
+		return []opentracing.LogRecord{{Timestamp: ts, Fields: fields}}
+	}
+
+	BeforeEach(func() {
+		enc = NewConsoleEncoder(ConsoleEncoderOptions{})
+		start = time.Date(2017, 3, 4, 15, 4, 5, 0, time.UTC)
+	})
+
+	It("encodes aligned columns with short IDs", func() {
+		sp := &SpanData{
+			operation: "GET /users",
+			context: spanContext{
+				traceID: TraceID{High: 1, Low: 0x1234567890abcdef},
+				spanID:  0xfedcba0987654321,
+			},
+			start:    start,
+			duration: -1,
+			tags:     map[string]interface{}{"http.method": "GET", "http.status_code": 200},
+			logs:     event(start, log.String("event", "Start-Span")),
+		}
+		Ω(string(enc.Encode(sp))).Should(Equal(
+			"15:04:05.000 90abcdef 87654321 Start-Span  GET /users http.method=GET http.status_code=200\n"))
+	})
+
+	It("encodes log fields and finish duration", func() {
+		sp := &SpanData{
+			operation: "op",
+			context: spanContext{
+				traceID: TraceID{Low: 1},
+				spanID:  1,
+				baggage: map[string]string{"tenant": "acme"},
+			},
+			start:    start,
+			finish:   start.Add(1500 * time.Microsecond),
+			duration: 1500 * time.Microsecond,
+			tags:     map[string]interface{}{"error": true},
+		}
+
+		sp.logs = event(start.Add(time.Millisecond),
+			log.String("event", "cache miss"),
+			log.String("key", "a b=c"),
+			log.Int("waited", 3),
+			log.Object("ids", []int{1, 2}))
+		Ω(string(enc.Encode(sp))).Should(Equal(
+			"15:04:05.001 00000001 00000001 cache miss  op key=\"a b=c\" waited=3 ids=[1,2]\n"))
+
+		sp.logs = event(sp.finish, log.String("event", "Finish-Span"))
+		Ω(string(enc.Encode(sp))).Should(Equal(
+			"15:04:05.001 00000001 00000001 Finish-Span op duration=1.5ms error=true baggage.tenant=acme\n"))
+	})
+
+	It("encodes all logs of single events", func() {
+		sp := &SpanData{
+			operation: "op",
+			context:   spanContext{traceID: TraceID{Low: 1}, spanID: 1},
+			start:     start,
+			finish:    start,
+			tags:      map[string]interface{}{"k": ""},
+			logs: []opentracing.LogRecord{
+				{Timestamp: start, Fields: []log.Field{log.String("event", "Start-Span")}},
+				{Timestamp: start, Fields: []log.Field{log.String("message", "hi")}},
+				{Timestamp: start, Fields: []log.Field{log.String("event", "Finish-Span")}},
+			},
+		}
+		Ω(string(enc.Encode(sp))).Should(Equal(
+			"15:04:05.000 00000001 00000001 Start-Span  op\n" +
+				"15:04:05.000 00000001 00000001 Log         op message=hi\n" +
+				"15:04:05.000 00000001 00000001 Finish-Span op duration=0s k=\"\"\n"))
+	})
+
+	It("indents Spans below their parents", func() {
+		trace := TraceID{Low: 1}
+		data := func(id, parent uint64, name string, finished bool) *SpanData {
+			d := &SpanData{
+				operation: name,
+				context:   spanContext{traceID: trace, spanID: id},
+				parentID:  parent,
+				start:     start,
+				duration:  -1,
+				logs:      event(start, log.String("event", "Start-Span")),
+			}
+			if finished {
+				d.duration = 0
+				d.logs = event(start, log.String("event", "Finish-Span"))
+			}
+			return d
+		}
+
+		var out bytes.Buffer
+		out.Write(enc.Encode(data(1, 0, "root", false)))
+		out.Write(enc.Encode(data(2, 1, "child", false)))
+		out.Write(enc.Encode(data(3, 2, "grandchild", false)))
+		out.Write(enc.Encode(data(3, 2, "grandchild", true)))
+		out.Write(enc.Encode(data(2, 1, "child", true)))
+		out.Write(enc.Encode(data(1, 0, "root", true)))
+		Ω(out.String()).Should(Equal(
+			"15:04:05.000 00000001 00000001 Start-Span  root\n" +
+				"15:04:05.000 00000001 00000002 Start-Span    child\n" +
+				"15:04:05.000 00000001 00000003 Start-Span      grandchild\n" +
+				"15:04:05.000 00000001 00000003 Finish-Span     grandchild duration=0s\n" +
+				"15:04:05.000 00000001 00000002 Finish-Span   child duration=0s\n" +
+				"15:04:05.000 00000001 00000001 Finish-Span root duration=0s\n"))
+		Ω(enc.(*consoleEncoder).depths).Should(BeEmpty())
+	})
+
+	It("colors events when Color is set", func() {
+		enc = NewConsoleEncoder(ConsoleEncoderOptions{Color: true})
+		sp := &SpanData{
+			operation: "op",
+			context:   spanContext{traceID: TraceID{Low: 1}, spanID: 1},
+			start:     start,
+			duration:  0,
+			tags:      map[string]interface{}{"error": true},
+			logs:      event(start, log.String("event", "Finish-Span")),
+		}
+		line := string(enc.Encode(sp))
+		Ω(line).Should(ContainSubstring(ansiRed + ansiBold + "Finish-Span" + ansiReset))
+		Ω(line).Should(ContainSubstring(traceColors[1] + "00000001" + ansiReset))
+	})
+
+	It("does not color by default", func() {
+		sp := &SpanData{
+			operation: "op",
+			context:   spanContext{traceID: TraceID{Low: 1}, spanID: 1},
+			start:     start,
+			duration:  -1,
+			logs:      event(start, log.String("event", "Start-Span")),
+		}
+		Ω(string(enc.Encode(sp))).ShouldNot(ContainSubstring("\x1b["))
+	})
+
+	It("escapes control characters in operations and events", func() {
+		enc = NewConsoleEncoder(ConsoleEncoderOptions{Color: true})
+		sp := &SpanData{
+			operation: "op\n15:04:05.000 forged\x1b[2J\xff",
+			context:   spanContext{traceID: TraceID{Low: 1}, spanID: 1},
+			start:     start,
+			duration:  -1,
+			logs:      event(start, log.String("event", "a\rb")),
+		}
+		line := string(enc.Encode(sp))
+		Ω(line).Should(HaveSuffix(`op\n15:04:05.000 forged\x1b[2J\xff` + "\n"))
+		Ω(line).Should(ContainSubstring(`a\rb` + ansiReset + "        "))
+		Ω(strings.Count(line, "\n")).Should(Equal(1))
+		Ω(line).ShouldNot(ContainSubstring("\x1b[2J"))
+	})
+
+	Describe("IsTerminal", func() {
+		It("is false for buffers and files", func() {
+			Ω(IsTerminal(&bytes.Buffer{})).Should(BeFalse())
+
+			r, w, err := os.Pipe()
+			Ω(err).ShouldNot(HaveOccurred())
+			defer r.Close()
+			defer w.Close()
+			Ω(IsTerminal(w)).Should(BeFalse())
+		})
+	})
+})
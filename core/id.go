@@ -0,0 +1,82 @@
+package core
+
+import (
+	"errors"
+	"strconv"
+)
+
+var errMalformedID = errors.New("ctrace: malformed trace or span ID")
+
+// TraceID is a 64 or 128-bit identifier shared by all Spans of a trace.  A
+// 64-bit TraceID has High set to 0.
+type TraceID struct {
+	High uint64
+	Low  uint64
+}
+
+// ParseTraceID parses a hex encoded TraceID of up to 32 digits.  Both 64-bit
+// and 128-bit IDs are accepted, with or without leading zeros.
+func ParseTraceID(s string) (TraceID, error) {
+	var t TraceID
+	var err error
+	if len(s) == 0 || len(s) > 32 {
+		return t, errMalformedID
+	}
+	if len(s) > 16 {
+		if t.High, err = parseSpanID(s[:len(s)-16]); err != nil {
+			return t, err
+		}
+		s = s[len(s)-16:]
+	}
+	if t.Low, err = parseSpanID(s); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+// IsValid tells whether the TraceID is non-zero.
+func (t TraceID) IsValid() bool {
+	return t.High != 0 || t.Low != 0
+}
+
+// Is128Bit tells whether the TraceID has a non-zero high part.
+func (t TraceID) Is128Bit() bool {
+	return t.High != 0
+}
+
+// String returns the TraceID as 16 (64-bit) or 32 (128-bit) lower case hex
+// digits.
+func (t TraceID) String() string {
+	return string(t.appendHex(make([]byte, 0, 32)))
+}
+
+func (t TraceID) appendHex(b []byte) []byte {
+	if t.High != 0 {
+		b = appendHexID(b, t.High)
+	}
+	return appendHexID(b, t.Low)
+}
+
+// appendHexID appends id as 16 zero-padded lower case hex digits.  It is the
+// one place IDs are formatted for output and propagation.
+func appendHexID(b []byte, id uint64) []byte {
+	for i := 60; i >= 0; i -= 4 {
+		b = append(b, _hex[(id>>uint(i))&0xF])
+	}
+	return b
+}
+
+func formatSpanID(id uint64) string {
+	return string(appendHexID(make([]byte, 0, 16), id))
+}
+
+func parseSpanID(s string) (uint64, error) {
+	if len(s) == 0 || len(s) > 16 {
+		return 0, errMalformedID
+	}
+	id, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, errMalformedID
+	}
+	return id, nil
+}
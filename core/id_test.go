@@ -0,0 +1,54 @@
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TraceID", func() {
+	Describe("String", func() {
+		It("formats 64-bit ids with padding", func() {
+			Ω(TraceID{Low: 123}.String()).Should(Equal("000000000000007b"))
+		})
+
+		It("formats 128-bit ids with padding", func() {
+			Ω(TraceID{High: 1, Low: 123}.String()).Should(Equal("0000000000000001000000000000007b"))
+		})
+	})
+
+	Describe("ParseTraceID", func() {
+		It("parses 64-bit ids", func() {
+			id, err := ParseTraceID("8a89382918382c7b")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(id).Should(Equal(TraceID{Low: 0x8a89382918382c7b}))
+			Ω(id.Is128Bit()).Should(BeFalse())
+		})
+
+		It("parses ids without padding", func() {
+			id, err := ParseTraceID("7b")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(id).Should(Equal(TraceID{Low: 123}))
+		})
+
+		It("parses 128-bit ids", func() {
+			id, err := ParseTraceID("4bf92f3577b34da6a3ce929d0e0e4736")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(id).Should(Equal(TraceID{High: 0x4bf92f3577b34da6, Low: 0xa3ce929d0e0e4736}))
+			Ω(id.Is128Bit()).Should(BeTrue())
+			Ω(id.String()).Should(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+		})
+
+		It("parses short 128-bit ids", func() {
+			id, err := ParseTraceID("1000000000000007b")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(id).Should(Equal(TraceID{High: 1, Low: 123}))
+		})
+
+		It("rejects malformed ids", func() {
+			for _, s := range []string{"", "xyz", "-7b", "4bf92f3577b34da6a3ce929d0e0e47361"} {
+				_, err := ParseTraceID(s)
+				Ω(err).Should(HaveOccurred(), s)
+			}
+		})
+	})
+})
@@ -1,14 +1,29 @@
 package core
 
 import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"math"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 	"unicode/utf8"
 )
 
+// Guards against encoding huge or cyclic tag and log field values.
+const (
+	maxValueDepth = 8
+	maxValueSize  = 16 << 10
+)
+
 // jsonEncoder is a fast / lite json encoder with just enough functionality to
-// support the span and log encoders.  It is the default (and at present only)
-// encoding supported by ctrace.
+// support the span and log encoders.  It is the default encoding supported by
+// ctrace.
 type jsonEncoder struct{}
 
 func (enc *jsonEncoder) encodeKeyString(bytes []byte, key, val string) []byte {
@@ -20,46 +35,254 @@ func (enc *jsonEncoder) encodeKeyString(bytes []byte, key, val string) []byte {
 }
 
 func (enc *jsonEncoder) encodeKeyValue(bytes []byte, k string, v interface{}) []byte {
+	bytes = enc.encodeKey(bytes, k)
+	return enc.encodeValue(bytes, v, 0, len(bytes)+maxValueSize)
+}
+
+// encodeValue encodes v as native JSON.  Nested values deeper than
+// maxValueDepth are encoded as "...", and the elements of arrays and objects
+// are cut off with a "..." element or key once the encoding reaches limit.
+func (enc *jsonEncoder) encodeValue(bytes []byte, v interface{}, depth int, limit int) []byte {
 	switch tval := v.(type) {
+	case nil:
+		return append(bytes, "null"...)
 	case bool:
-		bytes = enc.encodeKeyBool(bytes, k, tval)
+		return strconv.AppendBool(bytes, tval)
 	case string:
-		bytes = enc.encodeKeyString(bytes, k, tval)
+		return enc.encodeQuoted(bytes, tval)
 	case int:
-		bytes = enc.encodeKeyInt(bytes, k, int64(tval))
+		return strconv.AppendInt(bytes, int64(tval), 10)
 	case int8:
-		bytes = enc.encodeKeyInt(bytes, k, int64(tval))
+		return strconv.AppendInt(bytes, int64(tval), 10)
 	case int16:
-		bytes = enc.encodeKeyInt(bytes, k, int64(tval))
+		return strconv.AppendInt(bytes, int64(tval), 10)
 	case int32:
-		bytes = enc.encodeKeyInt(bytes, k, int64(tval))
+		return strconv.AppendInt(bytes, int64(tval), 10)
 	case int64:
-		bytes = enc.encodeKeyInt(bytes, k, tval)
+		return strconv.AppendInt(bytes, tval, 10)
 	case uint:
-		bytes = enc.encodeKeyUint(bytes, k, uint64(tval))
+		return strconv.AppendUint(bytes, uint64(tval), 10)
 	case uint8:
-		bytes = enc.encodeKeyUint(bytes, k, uint64(tval))
+		return strconv.AppendUint(bytes, uint64(tval), 10)
 	case uint16:
-		bytes = enc.encodeKeyUint(bytes, k, uint64(tval))
+		return strconv.AppendUint(bytes, uint64(tval), 10)
 	case uint32:
-		bytes = enc.encodeKeyUint(bytes, k, uint64(tval))
+		return strconv.AppendUint(bytes, uint64(tval), 10)
 	case uint64:
-		bytes = enc.encodeKeyUint(bytes, k, tval)
+		return strconv.AppendUint(bytes, tval, 10)
 	case float32:
-		bytes = enc.encodeKeyFloat(bytes, k, float64(tval))
+		return appendFloat(bytes, float64(tval))
 	case float64:
-		bytes = enc.encodeKeyFloat(bytes, k, tval)
-	default:
-		bytes = enc.encodeKeyString(bytes, k, fmt.Sprint(tval))
+		return appendFloat(bytes, tval)
+	case time.Time:
+		bytes = append(bytes, '"')
+		bytes = tval.AppendFormat(bytes, time.RFC3339Nano)
+		return append(bytes, '"')
+	case time.Duration:
+		// Microseconds, like the span timestamps.
+		return strconv.AppendInt(bytes, tval.Nanoseconds()/1e3, 10)
+	case json.Marshaler:
+		return enc.encodeMarshaler(bytes, tval, limit)
+	case error:
+		if isNilPointer(tval) {
+			return append(bytes, "null"...)
+		}
+		return enc.encodeQuoted(bytes, tval.Error())
+	case encoding.TextMarshaler:
+		if isNilPointer(tval) {
+			return append(bytes, "null"...)
+		}
+		text, err := tval.MarshalText()
+		if err != nil {
+			return enc.encodeQuoted(bytes, err.Error())
+		}
+		return enc.encodeQuoted(bytes, string(text))
 	}
 
-	return bytes
+	if depth >= maxValueDepth {
+		return enc.encodeQuoted(bytes, "...")
+	}
+	return enc.encodeReflected(bytes, reflect.ValueOf(v), depth, limit)
+}
+
+// encodeReflected encodes maps, slices, arrays, structs, and pointers to
+// them, and values of named types whose kind is primitive.
+func (enc *jsonEncoder) encodeReflected(bytes []byte, rv reflect.Value, depth int, limit int) []byte {
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return append(bytes, "null"...)
+		}
+		if rv.Elem().Kind() == reflect.Struct {
+			// Count only the struct's level, not the pointer's.
+			depth--
+		}
+		return enc.encodeElem(bytes, rv.Elem(), depth, limit)
+	case reflect.Bool:
+		return strconv.AppendBool(bytes, rv.Bool())
+	case reflect.String:
+		return enc.encodeQuoted(bytes, rv.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(bytes, rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.AppendUint(bytes, rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return appendFloat(bytes, rv.Float())
+	case reflect.Map:
+		return enc.encodeMap(bytes, rv, depth, limit)
+	case reflect.Slice:
+		if rv.IsNil() {
+			return append(bytes, "null"...)
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			bytes = append(bytes, '"')
+			bytes = append(bytes, base64.StdEncoding.EncodeToString(rv.Bytes())...)
+			return append(bytes, '"')
+		}
+		return enc.encodeArray(bytes, rv, depth, limit)
+	case reflect.Array:
+		return enc.encodeArray(bytes, rv, depth, limit)
+	case reflect.Struct:
+		return enc.encodeStruct(bytes, rv, depth, limit)
+	}
+	if rv.Kind() == reflect.Complex64 || rv.Kind() == reflect.Complex128 {
+		return enc.encodeQuoted(bytes, fmt.Sprint(rv.Interface()))
+	}
+	// Channels, functions, and unsafe pointers have no meaningful encoding.
+	return enc.encodeQuoted(bytes, "<unsupported "+rv.Type().String()+">")
+}
+
+// encodeElem encodes a nested value one level deeper.
+func (enc *jsonEncoder) encodeElem(bytes []byte, rv reflect.Value, depth int, limit int) []byte {
+	if !rv.CanInterface() {
+		return enc.encodeQuoted(bytes, fmt.Sprint(rv))
+	}
+	return enc.encodeValue(bytes, rv.Interface(), depth+1, limit)
+}
+
+func (enc *jsonEncoder) encodeArray(bytes []byte, rv reflect.Value, depth int, limit int) []byte {
+	bytes = append(bytes, '[')
+	for i := 0; i < rv.Len(); i++ {
+		if i > 0 {
+			bytes = append(bytes, ',')
+		}
+		if len(bytes) > limit {
+			bytes = enc.encodeQuoted(bytes, "...")
+			break
+		}
+		bytes = enc.encodeElem(bytes, rv.Index(i), depth, limit)
+	}
+	return append(bytes, ']')
+}
+
+func (enc *jsonEncoder) encodeMap(bytes []byte, rv reflect.Value, depth int, limit int) []byte {
+	if rv.IsNil() {
+		return append(bytes, "null"...)
+	}
+	// Sort the keys so equal maps have equal encodings.
+	keys := rv.MapKeys()
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		if k.Kind() == reflect.String {
+			names[i] = validKey(k.String())
+		} else {
+			names[i] = validKey(fmt.Sprint(k.Interface()))
+		}
+	}
+	sort.Sort(mapKeys{names, keys})
+
+	bytes = append(bytes, '{')
+	for i, k := range keys {
+		if i > 0 && names[i] == names[i-1] {
+			// Distinct keys with equal names, e.g. 1 and "1", are encoded once.
+			continue
+		}
+		if len(bytes) > limit {
+			if j := sort.SearchStrings(names[:i], "..."); j == i || names[j] != "..." {
+				bytes = enc.encodeKeyString(bytes, "...", "...")
+			}
+			break
+		}
+		bytes = enc.encodeKey(bytes, names[i])
+		bytes = enc.encodeElem(bytes, rv.MapIndex(k), depth, limit)
+	}
+	return append(bytes, '}')
+}
+
+// encodeStruct encodes the exported fields of a struct, named as by
+// encoding/json.
+func (enc *jsonEncoder) encodeStruct(bytes []byte, rv reflect.Value, depth int, limit int) []byte {
+	t := rv.Type()
+	var seen objectKeys
+	bytes = append(bytes, '{')
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		if len(bytes) > limit {
+			if seen.add("...") {
+				bytes = enc.encodeKeyString(bytes, "...", "...")
+			}
+			break
+		}
+		if !seen.add(validKey(name)) {
+			continue
+		}
+		bytes = enc.encodeKey(bytes, name)
+		bytes = enc.encodeElem(bytes, rv.Field(i), depth, limit)
+	}
+	return append(bytes, '}')
+}
+
+// encodeMarshaler encodes the compacted output of m, or its error as a string.
+// Output longer than the limit is encoded as "...".
+func (enc *jsonEncoder) encodeMarshaler(b []byte, m json.Marshaler, limit int) []byte {
+	if isNilPointer(m) {
+		return append(b, "null"...)
+	}
+	data, err := m.MarshalJSON()
+	if err != nil {
+		return enc.encodeQuoted(b, err.Error())
+	}
+	if len(b)+len(data) > limit {
+		return enc.encodeQuoted(b, "...")
+	}
+	if !utf8.Valid(data) {
+		// Not valid JSON text, so encode it as a string instead.
+		return enc.encodeQuoted(b, string(data))
+	}
+	buf := bytes.NewBuffer(b)
+	if err := json.Compact(buf, data); err != nil {
+		return enc.encodeQuoted(b, err.Error())
+	}
+	return buf.Bytes()
+}
+
+func (enc *jsonEncoder) encodeQuoted(bytes []byte, s string) []byte {
+	bytes = append(bytes, '"')
+	bytes = enc.encodeString(bytes, s)
+	return append(bytes, '"')
 }
 
 func (enc *jsonEncoder) encodeKeyID(bytes []byte, key string, id uint64) []byte {
 	bytes = enc.encodeKey(bytes, key)
 	bytes = append(bytes, '"')
-	bytes = enc.encodeString(bytes, fmt.Sprintf("%016x", id))
+	bytes = appendHexID(bytes, id)
+	bytes = append(bytes, '"')
+	return bytes
+}
+
+func (enc *jsonEncoder) encodeKeyTraceID(bytes []byte, key string, id TraceID) []byte {
+	bytes = enc.encodeKey(bytes, key)
+	bytes = append(bytes, '"')
+	bytes = id.appendHex(bytes)
 	bytes = append(bytes, '"')
 	return bytes
 }
@@ -78,8 +301,7 @@ func (enc *jsonEncoder) encodeKeyUint(bytes []byte, key string, u uint64) []byte
 
 func (enc *jsonEncoder) encodeKeyFloat(bytes []byte, key string, f float64) []byte {
 	bytes = enc.encodeKey(bytes, key)
-	bytes = strconv.AppendFloat(bytes, f, 'f', -1, 64)
-	return bytes
+	return appendFloat(bytes, f)
 }
 
 func (enc *jsonEncoder) encodeKeyBool(bytes []byte, key string, b bool) []byte {
@@ -139,3 +361,102 @@ func (enc *jsonEncoder) encodeString(bytes []byte, s string) []byte {
 	}
 	return bytes
 }
+
+// appendFloat appends f, or a string for NaN and infinities, which JSON
+// numbers cannot represent.
+func appendFloat(bytes []byte, f float64) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(bytes, `"NaN"`...)
+	case math.IsInf(f, 1):
+		return append(bytes, `"+Inf"`...)
+	case math.IsInf(f, -1):
+		return append(bytes, `"-Inf"`...)
+	}
+	return strconv.AppendFloat(bytes, f, 'f', -1, 64)
+}
+
+// validKey returns key with invalid UTF-8 replaced by U+FFFD, as encoded by
+// encodeString, so that keys can be compared as they are encoded.
+func validKey(key string) string {
+	if utf8.ValidString(key) {
+		return key
+	}
+	b := make([]byte, 0, len(key)+8)
+	for i := 0; i < len(key); {
+		c, size := utf8.DecodeRuneInString(key[i:])
+		if c == utf8.RuneError && size == 1 {
+			b = append(b, string(utf8.RuneError)...)
+		} else {
+			b = append(b, key[i:i+size]...)
+		}
+		i += size
+	}
+	return string(b)
+}
+
+// sortedValidKeys returns the keys of a string-keyed object and their encoded
+// names, sorted by name.  Keys with invalid UTF-8 whose name equals that of
+// a smaller key are dropped, so every name is encoded once.
+func sortedValidKeys(keys []string) (names, kept []string) {
+	sort.Strings(keys)
+	names = make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = validKey(k)
+	}
+	sort.Stable(stringKeys{names, keys})
+	n := 0
+	for i := range keys {
+		if i > 0 && names[i] == names[n-1] {
+			continue
+		}
+		names[n], keys[n] = names[i], keys[i]
+		n++
+	}
+	return names[:n], keys[:n]
+}
+
+// objectKeys tracks the keys of a small object to detect duplicates.
+type objectKeys []string
+
+// add adds key, returning false if it was added before.
+func (o *objectKeys) add(key string) bool {
+	for _, k := range *o {
+		if k == key {
+			return false
+		}
+	}
+	*o = append(*o, key)
+	return true
+}
+
+type stringKeys struct {
+	names []string
+	keys  []string
+}
+
+func (s stringKeys) Len() int           { return len(s.names) }
+func (s stringKeys) Less(i, j int) bool { return s.names[i] < s.names[j] }
+func (s stringKeys) Swap(i, j int) {
+	s.names[i], s.names[j] = s.names[j], s.names[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+// mapKeys sorts map keys by their names, and keys with equal names by their
+// Go syntax representation.
+type mapKeys struct {
+	names []string
+	keys  []reflect.Value
+}
+
+func (m mapKeys) Len() int { return len(m.names) }
+func (m mapKeys) Less(i, j int) bool {
+	if m.names[i] != m.names[j] {
+		return m.names[i] < m.names[j]
+	}
+	return fmt.Sprintf("%#v", m.keys[i].Interface()) < fmt.Sprintf("%#v", m.keys[j].Interface())
+}
+func (m mapKeys) Swap(i, j int) {
+	m.names[i], m.names[j] = m.names[j], m.names[i]
+	m.keys[i], m.keys[j] = m.keys[j], m.keys[i]
+}
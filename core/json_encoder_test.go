@@ -1,10 +1,43 @@
 package core
 
 import (
+	"errors"
+	"math"
+	"net"
+	"strings"
+	"time"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
+type jsonTestAddress struct {
+	Street string `json:"street"`
+	Zip    string `json:"-"`
+	City   *string
+	secret string
+}
+
+type jsonTestMarshaler struct{}
+
+func (jsonTestMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`{ "custom" : [1, 2] }`), nil
+}
+
+// jsonTestError and jsonTestText have pointer receivers, which panic when
+// called on nil pointers.
+type jsonTestError struct{ msg string }
+
+func (e *jsonTestError) Error() string { return e.msg }
+
+type jsonTestText struct{ text string }
+
+func (t *jsonTestText) MarshalText() ([]byte, error) { return []byte(t.text), nil }
+
+type jsonTestNode struct {
+	Next *jsonTestNode
+}
+
 var _ = Describe("jsonEncoder", func() {
 
 	var (
@@ -60,6 +93,18 @@ var _ = Describe("jsonEncoder", func() {
 		})
 	})
 
+	Describe("encodeKeyTraceID", func() {
+		It("encodes 64-bit id with padding", func() {
+			bytes = json.encodeKeyTraceID(bytes, "mykey", TraceID{Low: 123})
+			Ω(string(bytes)).Should(Equal(`"mykey":"000000000000007b"`))
+		})
+
+		It("encodes 128-bit id", func() {
+			bytes = json.encodeKeyTraceID(bytes, "mykey", TraceID{High: 0x4bf92f3577b34da6, Low: 0xa3ce929d0e0e4736})
+			Ω(string(bytes)).Should(Equal(`"mykey":"4bf92f3577b34da6a3ce929d0e0e4736"`))
+		})
+	})
+
 	Describe("encodeKeyInt", func() {
 		It("encodes simple int", func() {
 			bytes = json.encodeKeyInt(bytes, "mykey", 123)
@@ -163,6 +208,73 @@ var _ = Describe("jsonEncoder", func() {
 		})
 	})
 
+	Describe("encodeKeyValue with rich values", func() {
+		It("encodes nil", func() {
+			bytes = json.encodeKeyValue(bytes, "mykey", nil)
+			Ω(string(bytes)).Should(Equal(`"mykey":null`))
+		})
+
+		It("encodes maps with sorted keys", func() {
+			bytes = json.encodeKeyValue(bytes, "mykey", map[string]interface{}{
+				"b": []int{1, 2},
+				"a": map[int]bool{1: true},
+			})
+			Ω(string(bytes)).Should(Equal(`"mykey":{"a":{"1":true},"b":[1,2]}`))
+		})
+
+		It("encodes structs", func() {
+			bytes = json.encodeKeyValue(bytes, "mykey", &jsonTestAddress{Street: "Main", Zip: "98101", secret: "x"})
+			Ω(string(bytes)).Should(Equal(`"mykey":{"street":"Main","City":null}`))
+		})
+
+		It("encodes times, durations, errors, and text marshalers", func() {
+			t := time.Date(2017, 3, 4, 5, 6, 7, 8, time.UTC)
+			bytes = json.encodeKeyValue(bytes, "mykey", []interface{}{
+				t, 1500 * time.Microsecond, errors.New("boom"), net.ParseIP("10.0.0.1"), []byte("hi"),
+			})
+			Ω(string(bytes)).Should(Equal(`"mykey":["2017-03-04T05:06:07.000000008Z",1500,"boom","10.0.0.1","aGk="]`))
+		})
+
+		It("encodes typed nil errors and text marshalers as null", func() {
+			var err *jsonTestError
+			var text *jsonTestText
+			bytes = json.encodeKeyValue(bytes, "mykey", []interface{}{err, text, &jsonTestText{"t"}})
+			Ω(string(bytes)).Should(Equal(`"mykey":[null,null,"t"]`))
+		})
+
+		It("encodes NaN and infinities as strings", func() {
+			bytes = json.encodeKeyValue(bytes, "mykey", []float64{math.NaN(), math.Inf(1), math.Inf(-1)})
+			Ω(string(bytes)).Should(Equal(`"mykey":["NaN","+Inf","-Inf"]`))
+		})
+
+		It("encodes map keys with equal names once", func() {
+			bytes = json.encodeKeyValue(bytes, "mykey", map[interface{}]int{1: 1, "1": 2, "a\xff": 3, "a\xfe": 4})
+			Ω(string(bytes)).Should(Equal("\"mykey\":{\"1\":2,\"a\uFFFD\":4}"))
+		})
+
+		It("encodes json.Marshalers compactly", func() {
+			bytes = json.encodeKeyValue(bytes, "mykey", jsonTestMarshaler{})
+			Ω(string(bytes)).Should(Equal(`"mykey":{"custom":[1,2]}`))
+		})
+
+		It("limits the depth", func() {
+			n := &jsonTestNode{}
+			n.Next = n
+			bytes = json.encodeKeyValue(bytes, "mykey", n)
+			Ω(string(bytes)).Should(HaveSuffix(`{"Next":{"Next":"..."}}}}}}}}`))
+		})
+
+		It("limits the size", func() {
+			values := make([]string, 100)
+			for i := range values {
+				values[i] = strings.Repeat("x", 1024)
+			}
+			bytes = json.encodeKeyValue(bytes, "mykey", values)
+			Ω(len(bytes)).Should(BeNumerically("<", maxValueSize+2048))
+			Ω(string(bytes)).Should(HaveSuffix(`,"..."]`))
+		})
+	})
+
 	Describe("encodeString", func() {
 		It("encodes simple string", func() {
 			bytes = json.encodeString(bytes, "mystring")
@@ -0,0 +1,210 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// spanGenerator generates random spans with hostile strings and values.
+type spanGenerator struct {
+	rnd *rand.Rand
+}
+
+var generatorRunes = []string{
+	"a", "Z", "0", " ", "\"", "\\", "/", "\n", "\t", "\x00", "\x1f", "\x7f",
+	"é", "世", "😀", " ", "�", "\xff", "\xc3", "\xed\xa0\x80",
+}
+
+func (g spanGenerator) string() string {
+	var b []byte
+	for n := g.rnd.Intn(8); n > 0; n-- {
+		b = append(b, generatorRunes[g.rnd.Intn(len(generatorRunes))]...)
+	}
+	return string(b)
+}
+
+// key returns a random key, often one reserved by the encoder.
+func (g spanGenerator) key() string {
+	if g.rnd.Intn(4) == 0 {
+		reserved := []string{"timestamp", "_timestamp", "traceId", "operation", "...", "a"}
+		return reserved[g.rnd.Intn(len(reserved))]
+	}
+	return g.string()
+}
+
+func (g spanGenerator) float() float64 {
+	special := []float64{math.NaN(), math.Inf(1), math.Inf(-1), 0, math.MaxFloat64, math.SmallestNonzeroFloat64}
+	if g.rnd.Intn(3) == 0 {
+		return special[g.rnd.Intn(len(special))]
+	}
+	return g.rnd.NormFloat64() * 1e6
+}
+
+func (g spanGenerator) value(depth int) interface{} {
+	n := 12
+	if depth > 3 {
+		n = 9
+	}
+	switch g.rnd.Intn(n) {
+	case 0:
+		return nil
+	case 1:
+		return g.rnd.Intn(2) == 0
+	case 2:
+		return g.rnd.Int63() - g.rnd.Int63()
+	case 3:
+		return uint64(g.rnd.Int63()) << 1
+	case 4:
+		return g.float()
+	case 5:
+		return float32(g.float())
+	case 6:
+		return time.Duration(g.rnd.Int63())
+	case 7:
+		if g.rnd.Intn(4) == 0 {
+			return (*jsonTestError)(nil)
+		}
+		return errors.New(g.string())
+	case 8:
+		return g.string()
+	case 9:
+		a := make([]interface{}, g.rnd.Intn(4))
+		for i := range a {
+			a[i] = g.value(depth + 1)
+		}
+		return a
+	case 10:
+		m := make(map[string]interface{})
+		for i := g.rnd.Intn(4); i > 0; i-- {
+			m[g.key()] = g.value(depth + 1)
+		}
+		return m
+	default:
+		// Keys whose names collide.
+		return map[interface{}]interface{}{1: g.value(depth + 1), "1": g.string()}
+	}
+}
+
+func (g spanGenerator) field() log.Field {
+	key := g.key()
+	switch g.rnd.Intn(6) {
+	case 0:
+		return log.String(key, g.string())
+	case 1:
+		return log.Float64(key, g.float())
+	case 2:
+		return log.Float32(key, float32(g.float()))
+	case 3:
+		if g.rnd.Intn(4) == 0 {
+			return log.Error((*jsonTestError)(nil))
+		}
+		return log.Error(errors.New(g.string()))
+	case 4:
+		return log.Lazy(func(fe log.Encoder) {
+			fe.EmitString(key, g.string())
+			fe.EmitObject(key, g.value(0))
+		})
+	default:
+		return log.Object(key, g.value(0))
+	}
+}
+
+func (g spanGenerator) span() *SpanData {
+	sp := &SpanData{
+		context: spanContext{
+			traceID: TraceID{High: uint64(g.rnd.Int63()), Low: uint64(g.rnd.Int63())},
+			spanID:  uint64(g.rnd.Int63()),
+		},
+		parentID:  uint64(g.rnd.Int63()),
+		operation: g.string(),
+		start:     time.Now(),
+		duration:  time.Duration(g.rnd.Int63n(1e12)),
+		tags:      make(map[string]interface{}),
+	}
+	for i := g.rnd.Intn(6); i > 0; i-- {
+		sp.tags[g.key()] = g.value(0)
+	}
+	for i := g.rnd.Intn(3); i > 0; i-- {
+		sp.context = sp.context.WithBaggageItem(g.key(), g.string())
+	}
+	for i := g.rnd.Intn(3); i > 0; i-- {
+		lr := opentracing.LogRecord{Timestamp: time.Now()}
+		for j := g.rnd.Intn(5); j > 0; j-- {
+			lr.Fields = append(lr.Fields, g.field())
+		}
+		sp.logs = append(sp.logs, lr)
+	}
+	return sp
+}
+
+// checkJSON returns an error if data is not a single valid JSON value without
+// duplicate object keys.
+func checkJSON(data []byte) error {
+	if !json.Valid(data) {
+		return errors.New("invalid JSON")
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := checkJSONValue(dec); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("trailing data")
+	}
+	return nil
+}
+
+func checkJSONValue(dec *json.Decoder) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t {
+	case json.Delim('['):
+		for dec.More() {
+			if err := checkJSONValue(dec); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+	case json.Delim('{'):
+		keys := make(map[string]bool)
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if keys[k.(string)] {
+				return fmt.Errorf("duplicate key %q", k)
+			}
+			keys[k.(string)] = true
+			if err := checkJSONValue(dec); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+	}
+	return err
+}
+
+var _ = Describe("SpanEncoder properties", func() {
+	It("always encodes valid JSON without duplicate keys", func() {
+		g := spanGenerator{rand.New(rand.NewSource(GinkgoRandomSeed()))}
+		enc := NewSpanEncoder()
+		for i := 0; i < 2000; i++ {
+			out := enc.Encode(g.span())
+			Ω(out).Should(HaveSuffix("\n"))
+			Ω(checkJSON(out)).Should(Succeed(), "%s", out)
+		}
+	})
+})
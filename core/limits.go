@@ -0,0 +1,127 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// tagLimitReached tells whether setting a new tag named key would exceed the
+// MaxTags option.  Tags set by the tracer are not counted.
+func (s *span) tagLimitReached(key string) bool {
+	max := s.tracer.options.MaxTags
+	if max <= 0 || len(s.tags)-s.tracerTags < max {
+		return false
+	}
+	_, exists := s.tags[key]
+	return !exists
+}
+
+// logLimitReached tells whether recording another log would exceed the
+// MaxLogs option.
+func (s *span) logLimitReached() bool {
+	max := s.tracer.options.MaxLogs
+	return max > 0 && s.numLogs >= max
+}
+
+// baggageLimitReached tells whether setting the baggage item would exceed the
+// MaxBaggageBytes option.
+func (s *span) baggageLimitReached(key, val string) bool {
+	max := s.tracer.options.MaxBaggageBytes
+	if max <= 0 {
+		return false
+	}
+	size := len(key) + len(val)
+	for k, v := range s.context.baggage {
+		if k != key {
+			size += len(k) + len(v)
+		}
+	}
+	return size > max
+}
+
+// limitValue truncates strings longer than the MaxValueLength option,
+// including the strings nested in maps, slices, and structs, and the text of
+// errors.
+func (s *span) limitValue(value interface{}) interface{} {
+	max := s.tracer.options.MaxValueLength
+	if max <= 0 {
+		return value
+	}
+	v, _, _ := limitNested("", value, 0, max)
+	return v
+}
+
+// limitNested truncates value to max bytes as limitValue does, and reports
+// whether it changed, as a rewrite function for rewriteNested.
+func limitNested(key string, value interface{}, depth int, max int) (interface{}, bool, bool) {
+	if isNilPointer(value) {
+		// Calling its methods could panic, and it is encoded as null.
+		return value, true, false
+	}
+
+	var str string
+	switch tval := value.(type) {
+	case string:
+		str = tval
+	case error:
+		str = tval.Error()
+	case fmt.Stringer:
+		str = tval.String()
+	case json.Marshaler:
+		return value, true, false
+	default:
+		v, changed := rewriteNested(key, value, depth,
+			func(key string, value interface{}, depth int) (interface{}, bool, bool) {
+				return limitNested(key, value, depth, max)
+			})
+		return v, true, changed
+	}
+	if len(str) <= max {
+		return value, true, false
+	}
+	return truncate(str, max), true, true
+}
+
+// limitLog truncates the values of the fields of lr as limitValue does.
+// Fields whose value changes are replaced with string or object fields.
+func (s *span) limitLog(lr opentracing.LogRecord) opentracing.LogRecord {
+	max := s.tracer.options.MaxValueLength
+	if max <= 0 {
+		return lr
+	}
+	var fields []log.Field
+	for i, f := range lr.Fields {
+		v, _, changed := limitNested(f.Key(), f.Value(), 0, max)
+		if !changed {
+			continue
+		}
+		if fields == nil {
+			fields = append(make([]log.Field, 0, len(lr.Fields)), lr.Fields...)
+		}
+		if str, ok := v.(string); ok {
+			fields[i] = log.String(f.Key(), str)
+		} else {
+			fields[i] = log.Object(f.Key(), v)
+		}
+	}
+	if fields != nil {
+		lr.Fields = fields
+	}
+	return lr
+}
+
+// truncate shortens s to at most max bytes without splitting a UTF-8
+// character.  A max of 0 or less means no limit.
+func truncate(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
@@ -0,0 +1,150 @@
+package core_test
+
+import (
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/Nordstrom/ctrace-go/core"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+var _ = Describe("Limits", func() {
+	var (
+		buf core.Buffer
+		trc opentracing.Tracer
+	)
+
+	BeforeEach(func() {
+		buf.Reset()
+		trc = core.NewWithOptions(core.TracerOptions{
+			Writer:          &buf,
+			ServiceName:     "svc",
+			MaxTags:         2,
+			MaxLogs:         2,
+			MaxValueLength:  5,
+			MaxBaggageBytes: 10,
+		})
+	})
+
+	It("drops tags", func() {
+		sp := trc.StartSpan("op")
+		sp.SetTag("k1", 1)
+		sp.SetTag("k2", 2)
+		sp.SetTag("k3", 3)
+		sp.SetTag("service", "other")
+		sp.Finish()
+
+		out := buf.Spans()[0]
+		Ω(out.Tags).Should(HaveLen(3))
+		Ω(out.Tags).ShouldNot(HaveKey("k3"))
+		Ω(out.Tags["service"]).Should(Equal("other"))
+		Ω(out.DroppedTags).Should(Equal(1))
+	})
+
+	It("drops the same start tags and keeps the tracer's", func() {
+		for i := 0; i < 10; i++ {
+			buf.Reset()
+			trc.StartSpan("op", opentracing.Tags{
+				"d": 4, "b": 2, "service": "other", "a": 1, "c": 3,
+			}).Finish()
+
+			out := buf.Spans()[0]
+			Ω(out.Tags).Should(Equal(map[string]interface{}{
+				"service": "svc", "a": float64(1), "b": float64(2),
+			}))
+			Ω(out.DroppedTags).Should(Equal(2))
+		}
+	})
+
+	It("drops logs", func() {
+		sp := trc.StartSpan("op")
+		for i := 0; i < 5; i++ {
+			sp.LogFields(log.Int("i", i))
+		}
+		sp.Finish()
+
+		out := buf.Spans()[0]
+		Ω(out.Logs).Should(HaveLen(4))
+		Ω(out.Logs[3]["event"]).Should(Equal("Finish-Span"))
+		Ω(out.DroppedLogs).Should(Equal(3))
+		Ω(buf.String()).Should(ContainSubstring(`"droppedLogs":3`))
+	})
+
+	It("truncates values", func() {
+		sp := trc.StartSpan("op", opentracing.Tag{Key: "k", Value: "abcdefgh"})
+		sp.LogFields(log.String("msg", "héllo world"), log.Int("n", 123456789))
+		sp.Finish()
+
+		out := buf.Spans()[0]
+		Ω(out.Tags["service"]).Should(Equal("svc"))
+		Ω(out.Tags["k"]).Should(Equal("abcde"))
+		Ω(out.Logs[1]["msg"]).Should(Equal("héll"))
+		Ω(out.Logs[1]["n"]).Should(Equal(float64(123456789)))
+	})
+
+	It("truncates nested values", func() {
+		sp := trc.StartSpan("op", opentracing.Tag{Key: "k", Value: map[string]interface{}{
+			"list": []string{"abcdefgh", "ab"},
+			"n":    123456789,
+		}})
+		sp.LogFields(log.Object("obj", struct{ Msg string }{"abcdefgh"}))
+		sp.Finish()
+
+		out := buf.Spans()[0]
+		Ω(out.Tags["k"]).Should(Equal(map[string]interface{}{
+			"list": []interface{}{"abcde", "ab"},
+			"n":    float64(123456789),
+		}))
+		Ω(out.Logs[1]["obj"]).Should(Equal(map[string]interface{}{"Msg": "abcde"}))
+	})
+
+	It("keeps typed nil pointers", func() {
+		var u *url.URL
+		var err *os.PathError
+		sp := trc.StartSpan("op")
+		Ω(func() {
+			sp.SetTag("url", u)
+			sp.LogFields(log.Object("url", u), log.Error(err))
+		}).ShouldNot(Panic())
+		sp.Finish()
+
+		out := buf.Spans()[0]
+		Ω(out.Tags).Should(HaveKeyWithValue("url", BeNil()))
+		Ω(out.Logs[1]).Should(HaveKeyWithValue("url", BeNil()))
+		Ω(out.Logs[1]).Should(HaveKeyWithValue("error.object", BeNil()))
+	})
+
+	It("drops baggage", func() {
+		sp := trc.StartSpan("op")
+		sp.SetBaggageItem("k1", "v1")
+		sp.SetBaggageItem("k2", "v2")
+		sp.SetBaggageItem("k3", "v3")
+		sp.SetBaggageItem("k1", "v")
+		sp.Finish()
+
+		out := buf.Spans()[0]
+		Ω(out.Baggage).Should(Equal(map[string]string{"k1": "v", "k2": "v2"}))
+		Ω(out.DroppedBaggage).Should(Equal(1))
+	})
+
+	It("drops extracted baggage", func() {
+		parent := core.NewSpanContext(1, 2, map[string]string{
+			"k1": strings.Repeat("v", 20),
+		})
+		trc.StartSpan("op", opentracing.ChildOf(parent)).Finish()
+
+		out := buf.Spans()[0]
+		Ω(out.Baggage).Should(BeEmpty())
+		Ω(out.DroppedBaggage).Should(Equal(1))
+	})
+
+	It("does not output counters when nothing is dropped", func() {
+		trc.StartSpan("op").Finish()
+		Ω(buf.String()).ShouldNot(ContainSubstring("dropped"))
+	})
+})
@@ -0,0 +1,175 @@
+package core
+
+import (
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// logFieldEncoder is a log.Encoder appending log fields to a JSON object.
+// Each field is encoded according to its kind rather than its Value, so
+// numbers stay numbers, objects are encoded natively, and lazy fields emit
+// their own fields.
+type logFieldEncoder struct {
+	enc   *jsonEncoder
+	bytes []byte
+	keys  objectKeys
+}
+
+// key returns the name to encode a field as, or false if a field of that name
+// was encoded before.  Fields named "timestamp" are renamed "_timestamp", as
+// the name is taken by the timestamp of the log.
+func (e *logFieldEncoder) key(key string) (string, bool) {
+	if key == "timestamp" {
+		key = "_timestamp"
+	}
+	return key, e.keys.add(validKey(key))
+}
+
+func (e *logFieldEncoder) EmitString(key, value string) {
+	if key, ok := e.key(key); ok {
+		e.bytes = e.enc.encodeKeyString(e.bytes, key, value)
+	}
+}
+
+func (e *logFieldEncoder) EmitBool(key string, value bool) {
+	if key, ok := e.key(key); ok {
+		e.bytes = e.enc.encodeKeyBool(e.bytes, key, value)
+	}
+}
+
+func (e *logFieldEncoder) EmitInt(key string, value int) {
+	if key, ok := e.key(key); ok {
+		e.bytes = e.enc.encodeKeyInt(e.bytes, key, int64(value))
+	}
+}
+
+func (e *logFieldEncoder) EmitInt32(key string, value int32) {
+	if key, ok := e.key(key); ok {
+		e.bytes = e.enc.encodeKeyInt(e.bytes, key, int64(value))
+	}
+}
+
+func (e *logFieldEncoder) EmitInt64(key string, value int64) {
+	if key, ok := e.key(key); ok {
+		e.bytes = e.enc.encodeKeyInt(e.bytes, key, value)
+	}
+}
+
+func (e *logFieldEncoder) EmitUint32(key string, value uint32) {
+	if key, ok := e.key(key); ok {
+		e.bytes = e.enc.encodeKeyUint(e.bytes, key, uint64(value))
+	}
+}
+
+func (e *logFieldEncoder) EmitUint64(key string, value uint64) {
+	if key, ok := e.key(key); ok {
+		e.bytes = e.enc.encodeKeyUint(e.bytes, key, value)
+	}
+}
+
+func (e *logFieldEncoder) EmitFloat32(key string, value float32) {
+	if key, ok := e.key(key); ok {
+		e.bytes = e.enc.encodeKeyFloat(e.bytes, key, float64(value))
+	}
+}
+
+func (e *logFieldEncoder) EmitFloat64(key string, value float64) {
+	if key, ok := e.key(key); ok {
+		e.bytes = e.enc.encodeKeyFloat(e.bytes, key, value)
+	}
+}
+
+func (e *logFieldEncoder) EmitObject(key string, value interface{}) {
+	if key, ok := e.key(key); ok {
+		e.bytes = e.enc.encodeKeyValue(e.bytes, key, value)
+	}
+}
+
+func (e *logFieldEncoder) EmitLazyLogger(value log.LazyLogger) {
+	if value != nil {
+		value(e)
+	}
+}
+
+// marshalField marshals f to e.  Typed nil pointers are emitted as nil
+// objects, as log.Field.Marshal would panic calling the Error method of a nil
+// error.
+func marshalField(f log.Field, e log.Encoder) {
+	if isNilPointer(f.Value()) {
+		e.EmitObject(f.Key(), nil)
+		return
+	}
+	f.Marshal(e)
+}
+
+// fieldCollector is a log.Encoder collecting the fields emitted by lazy
+// fields, so they can be redacted like any other field.
+type fieldCollector []log.Field
+
+func (c *fieldCollector) EmitString(key, value string) {
+	*c = append(*c, log.String(key, value))
+}
+
+func (c *fieldCollector) EmitBool(key string, value bool) {
+	*c = append(*c, log.Bool(key, value))
+}
+
+func (c *fieldCollector) EmitInt(key string, value int) {
+	*c = append(*c, log.Int(key, value))
+}
+
+func (c *fieldCollector) EmitInt32(key string, value int32) {
+	*c = append(*c, log.Int32(key, value))
+}
+
+func (c *fieldCollector) EmitInt64(key string, value int64) {
+	*c = append(*c, log.Int64(key, value))
+}
+
+func (c *fieldCollector) EmitUint32(key string, value uint32) {
+	*c = append(*c, log.Uint32(key, value))
+}
+
+func (c *fieldCollector) EmitUint64(key string, value uint64) {
+	*c = append(*c, log.Uint64(key, value))
+}
+
+func (c *fieldCollector) EmitFloat32(key string, value float32) {
+	*c = append(*c, log.Float32(key, value))
+}
+
+func (c *fieldCollector) EmitFloat64(key string, value float64) {
+	*c = append(*c, log.Float64(key, value))
+}
+
+func (c *fieldCollector) EmitObject(key string, value interface{}) {
+	*c = append(*c, log.Object(key, value))
+}
+
+func (c *fieldCollector) EmitLazyLogger(value log.LazyLogger) {
+	if value != nil {
+		value(c)
+	}
+}
+
+// expandLazyFields returns fields with each lazy field replaced by the fields
+// it emits.
+func expandLazyFields(fields []log.Field) []log.Field {
+	var expanded fieldCollector
+	for i, f := range fields {
+		ll, ok := f.Value().(log.LazyLogger)
+		if !ok {
+			if expanded != nil {
+				expanded = append(expanded, f)
+			}
+			continue
+		}
+		if expanded == nil {
+			expanded = append(make(fieldCollector, 0, len(fields)), fields[:i]...)
+		}
+		expanded.EmitLazyLogger(ll)
+	}
+	if expanded == nil {
+		return fields
+	}
+	return expanded
+}
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// ReadOnlySpan is a read-only view of a Span passed to SpanProcessors.  It is
+// only valid for the duration of the call it is passed to.
+type ReadOnlySpan interface {
+	// Context returns the SpanContext of the Span.
+	Context() SpanContext
+
+	// ParentID returns the span ID of the parent Span, or 0 for a root Span.
+	ParentID() uint64
+
+	// Operation returns the operation name of the Span.
+	Operation() string
+
+	// StartTime returns the time the Span started.
+	StartTime() time.Time
+
+	// FinishTime returns the time the Span finished, or the zero time if it
+	// is not finished yet.
+	FinishTime() time.Time
+
+	// Duration returns the duration of the Span, or a negative value if it
+	// is not finished yet.
+	Duration() time.Duration
+
+	// Tag returns the value of the tag named key, or nil if it is not set.
+	Tag(key string) interface{}
+
+	// ForeachTag calls handler for each tag of the Span until it returns
+	// false.
+	ForeachTag(handler func(key string, value interface{}) bool)
+}
+
+// SpanProcessor is called by the Tracer when a sampled Span starts, logs, and
+// finishes, before the event is passed to the SpanReporter.  Processors are
+// called in the order they are registered in TracerOptions.Processors and
+// while the Span is locked, so they must not call methods of the Span itself.
+type SpanProcessor interface {
+	// OnStart is called when a Span starts.  Returning false filters the
+	// Span; none of its events are reported and no further processor hooks
+	// are called for it.
+	OnStart(sp ReadOnlySpan) bool
+
+	// OnLog is called when a log is recorded on a Span.  Returning false
+	// filters the log.
+	OnLog(sp ReadOnlySpan, lr opentracing.LogRecord) bool
+
+	// OnFinish is called when a Span finishes.  Returning false filters the
+	// Finish-Span event, and with it the whole Span in Single-Event Mode.
+	OnFinish(sp ReadOnlySpan) bool
+}
+
+// SpanEnricher may be implemented by a SpanProcessor to add tags to Spans.
+// Enrich is called right before OnStart and OnFinish, and the tags it
+// returns are set on the Span.
+type SpanEnricher interface {
+	Enrich(sp ReadOnlySpan) []opentracing.Tag
+}
+
+// spanView implements ReadOnlySpan over a locked span.  It does not embed the
+// span so processors cannot reach its mutating methods.
+type spanView struct {
+	s *span
+}
+
+func (v spanView) Context() SpanContext {
+	return v.s.context
+}
+
+func (v spanView) ParentID() uint64 {
+	return v.s.parentID
+}
+
+func (v spanView) Operation() string {
+	return v.s.operation
+}
+
+func (v spanView) StartTime() time.Time {
+	return v.s.start
+}
+
+func (v spanView) FinishTime() time.Time {
+	return v.s.finish
+}
+
+func (v spanView) Duration() time.Duration {
+	return v.s.duration
+}
+
+func (v spanView) Tag(key string) interface{} {
+	return v.s.tags[key]
+}
+
+func (v spanView) ForeachTag(handler func(key string, value interface{}) bool) {
+	for k, v := range v.s.tags {
+		if !handler(k, v) {
+			break
+		}
+	}
+}
+
+// processStart runs the processors for a starting span, and tells whether
+// the span is to be reported.
+func (s *span) processStart() bool {
+	for _, p := range s.tracer.options.Processors {
+		s.enrich(p)
+		if !p.OnStart(spanView{s}) {
+			s.filtered = true
+			return false
+		}
+	}
+	return true
+}
+
+// processLog runs the processors for a log, and tells whether the log is to
+// be recorded.
+func (s *span) processLog(lr opentracing.LogRecord) bool {
+	for _, p := range s.tracer.options.Processors {
+		if !p.OnLog(spanView{s}, lr) {
+			return false
+		}
+	}
+	return true
+}
+
+// processFinish runs the processors for a finishing span, and tells whether
+// the Finish-Span event is to be reported.
+func (s *span) processFinish() bool {
+	for _, p := range s.tracer.options.Processors {
+		s.enrich(p)
+		if !p.OnFinish(spanView{s}) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *span) enrich(p SpanProcessor) {
+	if e, ok := p.(SpanEnricher); ok {
+		for _, tag := range e.Enrich(spanView{s}) {
+			s.setTag(tag.Key, tag.Value)
+		}
+	}
+}
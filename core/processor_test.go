@@ -0,0 +1,134 @@
+package core_test
+
+import (
+	"time"
+
+	"github.com/Nordstrom/ctrace-go/core"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// testProcessor records the hooks it is called with, and filters operations,
+// logs, and finishes named in its maps.
+type testProcessor struct {
+	name        string
+	calls       *[]string
+	filterStart map[string]bool
+	filterLog   map[string]bool
+	duration    time.Duration
+}
+
+func (p *testProcessor) OnStart(sp core.ReadOnlySpan) bool {
+	*p.calls = append(*p.calls, p.name+":start:"+sp.Operation())
+	return !p.filterStart[sp.Operation()]
+}
+
+func (p *testProcessor) OnLog(sp core.ReadOnlySpan, lr opentracing.LogRecord) bool {
+	*p.calls = append(*p.calls, p.name+":log:"+sp.Operation())
+	for _, f := range lr.Fields {
+		if p.filterLog[f.Key()] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *testProcessor) OnFinish(sp core.ReadOnlySpan) bool {
+	*p.calls = append(*p.calls, p.name+":finish:"+sp.Operation())
+	p.duration = sp.Duration()
+	return true
+}
+
+type testEnricher struct {
+	testProcessor
+}
+
+func (e *testEnricher) Enrich(sp core.ReadOnlySpan) []opentracing.Tag {
+	if sp.Duration() < 0 {
+		return []opentracing.Tag{{Key: "enriched", Value: "start"}}
+	}
+	return []opentracing.Tag{{Key: "enriched", Value: "finish"}}
+}
+
+var _ = Describe("SpanProcessor", func() {
+	var (
+		buf   core.Buffer
+		calls []string
+		p1    *testProcessor
+		p2    *testProcessor
+		trc   opentracing.Tracer
+	)
+
+	BeforeEach(func() {
+		buf.Reset()
+		calls = nil
+		p1 = &testProcessor{name: "p1", calls: &calls}
+		p2 = &testProcessor{name: "p2", calls: &calls}
+		trc = core.NewWithOptions(core.TracerOptions{
+			Writer:     &buf,
+			MultiEvent: true,
+			Processors: []core.SpanProcessor{p1, p2},
+		})
+	})
+
+	It("calls processors in order", func() {
+		sp := trc.StartSpan("op")
+		sp.LogFields(log.String("event", "e"))
+		sp.FinishWithOptions(opentracing.FinishOptions{
+			FinishTime: time.Now().Add(time.Second),
+		})
+		Ω(calls).Should(Equal([]string{
+			"p1:start:op", "p2:start:op",
+			"p1:log:op", "p2:log:op",
+			"p1:finish:op", "p2:finish:op",
+		}))
+		Ω(p2.duration).Should(BeNumerically(">=", time.Second))
+		Ω(buf.Spans()).Should(HaveLen(3))
+	})
+
+	It("filters spans", func() {
+		p1.filterStart = map[string]bool{"health": true}
+		sp := trc.StartSpan("health")
+		sp.LogFields(log.String("event", "e"))
+		sp.Finish()
+		Ω(calls).Should(Equal([]string{"p1:start:health"}))
+		Ω(buf.Len()).Should(BeZero())
+	})
+
+	It("filters logs", func() {
+		p2.filterLog = map[string]bool{"secret": true}
+		sp := trc.StartSpan("op")
+		sp.LogFields(log.String("secret", "s"))
+		sp.LogFields(log.String("event", "e"))
+		sp.Finish()
+		spans := buf.Spans()
+		Ω(spans).Should(HaveLen(3))
+		Ω(spans[1].Logs[0]["event"]).Should(Equal("e"))
+	})
+
+	It("records logs passed to FinishWithOptions", func() {
+		sp := trc.StartSpan("op")
+		sp.FinishWithOptions(opentracing.FinishOptions{
+			LogRecords: []opentracing.LogRecord{{
+				Fields: []log.Field{log.String("event", "e")},
+			}},
+		})
+		Ω(buf.Spans()).Should(HaveLen(3))
+	})
+
+	It("enriches spans", func() {
+		e := &testEnricher{testProcessor{name: "e", calls: &calls}}
+		trc = core.NewWithOptions(core.TracerOptions{
+			Writer:     &buf,
+			MultiEvent: true,
+			Processors: []core.SpanProcessor{e},
+		})
+		trc.StartSpan("op").Finish()
+		spans := buf.Spans()
+		Ω(spans[0].Tags["enriched"]).Should(Equal("start"))
+		Ω(spans[1].Tags["enriched"]).Should(Equal("finish"))
+	})
+})
@@ -45,6 +45,14 @@ const (
 	//
 	// For Tracer.Extract(): the carrier must be a `TextMapReader`.
 	TextMap = opentracing.TextMap
+
+	// Binary represents SpanContexts as an opaque, versioned binary blob.
+	//
+	// For Tracer.Inject(): the carrier must be an `io.Writer` or a `*[]byte`.
+	//
+	// For Tracer.Extract(): the carrier must be an `io.Reader`, a `[]byte`,
+	// or a `*[]byte`.
+	Binary = opentracing.Binary
 )
 
 // Injector is responsible for injecting SpanContext instances in a manner suitable
@@ -57,7 +65,7 @@ type Injector interface {
 	//
 	// Implementations may return opentracing.ErrInvalidCarrier or any other
 	// implementation-specific error if injection fails.
-	Inject(ctx opentracing.SpanContext, carrier interface{})
+	Inject(ctx opentracing.SpanContext, carrier interface{}) error
 }
 
 // Extractor is responsible for extracting SpanContext instances from a
@@ -71,6 +79,14 @@ type Extractor interface {
 	Extract(carrier interface{}) (opentracing.SpanContext, error)
 }
 
+// Propagator injects SpanContexts into and extracts them from carriers using a
+// particular wire format.  The Propagators for the TextMap and HTTPHeaders
+// formats are set in TracerOptions.
+type Propagator interface {
+	Injector
+	Extractor
+}
+
 // TextMapWriter is the Inject() carrier for the TextMap builtin format. With
 // it, the caller can encode a SpanContext for propagation as entries in a map
 // of unicode strings.
@@ -107,58 +123,171 @@ func (c HTTPHeadersCarrier) Set(key, val string) {
 	c[key] = []string{val}
 }
 
+// ForeachKey conforms to the opentracing.TextMapReader interface.
+func (c TextMapCarrier) ForeachKey(handler func(key, val string) error) error {
+	return opentracing.TextMapCarrier(c).ForeachKey(handler)
+}
+
+// ForeachKey conforms to the opentracing.TextMapReader interface.
+func (c HTTPHeadersCarrier) ForeachKey(handler func(key, val string) error) error {
+	return opentracing.HTTPHeadersCarrier(c).ForeachKey(handler)
+}
+
+// PropagationKeys names the keys used by the default TextMap and HTTPHeaders
+// Propagators.  Empty fields default to the ct-* keys.
+type PropagationKeys struct {
+	TraceID       string
+	SpanID        string
+	Sampled       string
+	Debug         string
+	BaggagePrefix string
+}
+
+var defaultPropagationKeys = PropagationKeys{
+	TraceID:       "ct-trace-id",
+	SpanID:        "ct-span-id",
+	Sampled:       "ct-sampled",
+	Debug:         "ct-debug",
+	BaggagePrefix: "ct-bag-",
+}
+
+// withDefaults fills the empty fields of k with the ct-* keys.
+func (k PropagationKeys) withDefaults() PropagationKeys {
+	if k.TraceID == "" {
+		k.TraceID = defaultPropagationKeys.TraceID
+	}
+	if k.SpanID == "" {
+		k.SpanID = defaultPropagationKeys.SpanID
+	}
+	if k.Sampled == "" {
+		k.Sampled = defaultPropagationKeys.Sampled
+	}
+	if k.Debug == "" {
+		k.Debug = defaultPropagationKeys.Debug
+	}
+	if k.BaggagePrefix == "" {
+		k.BaggagePrefix = defaultPropagationKeys.BaggagePrefix
+	}
+	return k
+}
+
+func (k PropagationKeys) toLower() PropagationKeys {
+	return PropagationKeys{
+		TraceID:       strings.ToLower(k.TraceID),
+		SpanID:        strings.ToLower(k.SpanID),
+		Sampled:       strings.ToLower(k.Sampled),
+		Debug:         strings.ToLower(k.Debug),
+		BaggagePrefix: strings.ToLower(k.BaggagePrefix),
+	}
+}
+
 type textMapPropagator struct {
 	traceIDKey    string
 	spanIDKey     string
+	sampledKey    string
+	debugKey      string
 	baggagePrefix string
+	aliases       []PropagationKeys
 	encodeKey     func(string) string
 	decodeKey     func(string) string
 	encodeValue   func(string) string
 	decodeValue   func(string) string
 }
 
-func newTextMapPropagator() *textMapPropagator {
+// NewTextMapPropagator creates the default Propagator of the TextMap format,
+// which uses the ct-trace-id, ct-span-id, ct-sampled, and ct-debug keys, and
+// ct-bag-* keys for baggage.  The first keys given replace these, and any
+// further keys are aliases accepted by Extract but never injected, e.g. the
+// old keys while migrating to new ones.
+func NewTextMapPropagator(keys ...PropagationKeys) Propagator {
+	return newTextMapPropagator(keys...)
+}
+
+// NewHTTPHeadersPropagator creates the default Propagator of the HTTPHeaders
+// format, which uses the same keys as NewTextMapPropagator with URL-encoded
+// baggage.  Keys are matched case-insensitively.
+func NewHTTPHeadersPropagator(keys ...PropagationKeys) Propagator {
+	return newHTTPHeadersPropagator(keys...)
+}
+
+func newTextMapPropagator(keys ...PropagationKeys) *textMapPropagator {
 	var passthrough = func(s string) string {
 		return s
 	}
 
-	return &textMapPropagator{
-		traceIDKey:    "ct-trace-id",
-		spanIDKey:     "ct-span-id",
-		baggagePrefix: "ct-bag-",
-		encodeKey:     passthrough,
-		decodeKey:     passthrough,
-		encodeValue:   passthrough,
-		decodeValue:   passthrough,
+	p := newKeyedPropagator(keys)
+	p.encodeKey = passthrough
+	p.decodeKey = passthrough
+	p.encodeValue = passthrough
+	p.decodeValue = passthrough
+	return p
+}
+
+func newHTTPHeadersPropagator(keys ...PropagationKeys) *textMapPropagator {
+	// decodeKey lower-cases the headers, so the keys must be too.
+	lower := make([]PropagationKeys, len(keys))
+	for i, k := range keys {
+		lower[i] = k.toLower()
+	}
+
+	p := newKeyedPropagator(lower)
+	p.encodeKey = func(key string) string {
+		return url.QueryEscape(key)
+	}
+	p.decodeKey = func(key string) string {
+		// ignore decoding errors, cannot do anything about them
+		if k, err := url.QueryUnescape(key); err == nil {
+			return strings.ToLower(k)
+		}
+		return strings.ToLower(key)
+	}
+	p.encodeValue = func(val string) string {
+		return url.QueryEscape(val)
 	}
+	p.decodeValue = func(val string) string {
+		// ignore decoding errors, cannot do anything about them
+		if v, err := url.QueryUnescape(val); err == nil {
+			return v
+		}
+		return val
+	}
+	return p
 }
 
-func newHTTPHeadersPropagator() *textMapPropagator {
+func newKeyedPropagator(keys []PropagationKeys) *textMapPropagator {
+	var primary PropagationKeys
+	if len(keys) > 0 {
+		primary, keys = keys[0], keys[1:]
+	}
+	primary = primary.withDefaults()
 	return &textMapPropagator{
-		traceIDKey:    "ct-trace-id",
-		spanIDKey:     "ct-span-id",
-		baggagePrefix: "ct-bag-",
-		encodeKey: func(key string) string {
-			return url.QueryEscape(key)
-		},
-		decodeKey: func(key string) string {
-			// ignore decoding errors, cannot do anything about them
-			if k, err := url.QueryUnescape(key); err == nil {
-				return strings.ToLower(k)
-			}
-			return strings.ToLower(key)
-		},
-		encodeValue: func(val string) string {
-			return url.QueryEscape(val)
-		},
-		decodeValue: func(val string) string {
-			// ignore decoding errors, cannot do anything about them
-			if v, err := url.QueryUnescape(val); err == nil {
-				return v
-			}
-			return val
-		},
+		traceIDKey:    primary.TraceID,
+		spanIDKey:     primary.SpanID,
+		sampledKey:    primary.Sampled,
+		debugKey:      primary.Debug,
+		baggagePrefix: primary.BaggagePrefix,
+		aliases:       keys,
+	}
+}
+
+// canonicalKey maps a key matching one of the aliases to the corresponding
+// primary key.  Empty alias fields match nothing.
+func (p *textMapPropagator) canonicalKey(k string) string {
+	for _, a := range p.aliases {
+		switch {
+		case a.TraceID != "" && k == a.TraceID:
+			return p.traceIDKey
+		case a.SpanID != "" && k == a.SpanID:
+			return p.spanIDKey
+		case a.Sampled != "" && k == a.Sampled:
+			return p.sampledKey
+		case a.Debug != "" && k == a.Debug:
+			return p.debugKey
+		case a.BaggagePrefix != "" && strings.HasPrefix(k, a.BaggagePrefix):
+			return p.baggagePrefix + strings.TrimPrefix(k, a.BaggagePrefix)
+		}
 	}
+	return k
 }
 
 func (p *textMapPropagator) Inject(
@@ -173,10 +302,16 @@ func (p *textMapPropagator) Inject(
 	if !ok {
 		return opentracing.ErrInvalidCarrier
 	}
-	// TODO: At this point we don't need to encode trace and span id values
-	// this may change
-	carrier.Set(p.traceIDKey, strconv.FormatUint(sc.traceID, 16))
-	carrier.Set(p.spanIDKey, strconv.FormatUint(sc.spanID, 16))
+	carrier.Set(p.traceIDKey, sc.traceID.String())
+	carrier.Set(p.spanIDKey, formatSpanID(sc.spanID))
+	if sc.sampled {
+		carrier.Set(p.sampledKey, "1")
+	} else {
+		carrier.Set(p.sampledKey, "0")
+	}
+	if sc.debug {
+		carrier.Set(p.debugKey, "1")
+	}
 
 	for k, v := range sc.baggage {
 		carrier.Set(p.baggagePrefix+p.encodeKey(k), p.encodeValue(v))
@@ -192,29 +327,48 @@ func (p *textMapPropagator) Extract(
 		return nil, opentracing.ErrInvalidCarrier
 	}
 	requiredFieldCount := 0
-	var traceID, spanID uint64
+	var traceID TraceID
+	var spanID uint64
 	var err error
+	// Callers that predate the sampled key report every trace.
+	sampled, debug := true, false
 
 	decodedBaggage := make(map[string]string)
 	err = carrier.ForeachKey(func(k, v string) error {
 		k = p.decodeKey(k)
+		if len(p.aliases) > 0 {
+			k = p.canonicalKey(k)
+		}
 		switch k {
 		case p.traceIDKey:
-			traceID, err = strconv.ParseUint(v, 16, 64)
+			traceID, err = ParseTraceID(v)
 			if err != nil {
 				return opentracing.ErrSpanContextCorrupted
 			}
 		case p.spanIDKey:
-			spanID, err = strconv.ParseUint(v, 16, 64)
+			spanID, err = parseSpanID(v)
+			if err != nil {
+				return opentracing.ErrSpanContextCorrupted
+			}
+		case p.sampledKey:
+			sampled, err = strconv.ParseBool(v)
 			if err != nil {
 				return opentracing.ErrSpanContextCorrupted
 			}
+			// Balance off the requiredFieldCount++ just below...
+			requiredFieldCount--
+		case p.debugKey:
+			// Any value other than an explicit false turns debugging on, so
+			// a request can be tagged with e.g. a ticket number.
+			if b, err := strconv.ParseBool(v); err != nil || b {
+				debug = true
+			}
+			requiredFieldCount--
 		default:
 			if strings.HasPrefix(k, p.baggagePrefix) {
 				key := strings.TrimPrefix(k, p.baggagePrefix)
-				decodedBaggage[key] = v
+				decodedBaggage[key] = p.decodeValue(v)
 			}
-			// Balance off the requiredFieldCount++ just below...
 			requiredFieldCount--
 		}
 		requiredFieldCount++
@@ -223,6 +377,14 @@ func (p *textMapPropagator) Extract(
 	if err != nil {
 		return nil, err
 	}
+	if requiredFieldCount == 0 && debug {
+		// A debug request starting a new trace.
+		return spanContext{
+			sampled: true,
+			debug:   true,
+			baggage: decodedBaggage,
+		}, nil
+	}
 	if requiredFieldCount < 2 {
 		if requiredFieldCount == 0 {
 			return nil, opentracing.ErrSpanContextNotFound
@@ -233,6 +395,8 @@ func (p *textMapPropagator) Extract(
 	return spanContext{
 		traceID: traceID,
 		spanID:  spanID,
+		sampled: sampled || debug,
+		debug:   debug,
 		baggage: decodedBaggage,
 	}, nil
 }
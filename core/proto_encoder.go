@@ -0,0 +1,356 @@
+package core
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// Protobuf wire types.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+)
+
+// Field numbers of the messages in span.proto.
+const (
+	spanTraceIDHigh    = 1
+	spanTraceIDLow     = 2
+	spanSpanID         = 3
+	spanParentID       = 4
+	spanReferences     = 5
+	spanOperation      = 6
+	spanStart          = 7
+	spanFinish         = 8
+	spanDuration       = 9
+	spanTags           = 10
+	spanBaggage        = 11
+	spanLogs           = 12
+	spanDroppedTags    = 13
+	spanDroppedLogs    = 14
+	spanDroppedBaggage = 15
+	spanEvent          = 16
+
+	referenceType        = 1
+	referenceTraceIDHigh = 2
+	referenceTraceIDLow  = 3
+	referenceSpanID      = 4
+
+	logTimestamp = 1
+	logFields    = 2
+
+	fieldKey   = 1
+	fieldValue = 2
+
+	mapKey   = 1
+	mapValue = 2
+
+	valueString = 1
+	valueBool   = 2
+	valueInt    = 3
+	valueUint   = 4
+	valueDouble = 5
+	valueJSON   = 6
+)
+
+// Values of the Span.Event enum.
+const (
+	protoStartSpan  = 0
+	protoLog        = 1
+	protoFinishSpan = 2
+)
+
+// protoEncoder is a SpanEncoder writing length-delimited protobuf messages
+// described by span.proto.  It appends the wire format directly, without
+// generated code or reflection, like jsonEncoder does for JSON.
+type protoEncoder struct {
+	json jsonEncoder
+}
+
+// NewProtobufSpanEncoder creates a SpanEncoder writing each event as a Span
+// message of span.proto, prefixed with its length as a varint.  It is
+// smaller and cheaper to produce than JSON for high-volume services.
+func NewProtobufSpanEncoder() SpanEncoder {
+	return &protoEncoder{}
+}
+
+func (enc *protoEncoder) Encode(sp *SpanData) []byte {
+	bytes := make([]byte, 0, 512)
+	mark := len(bytes)
+	bytes = beginProtoLength(bytes)
+
+	bytes = appendProtoFixed64(bytes, spanTraceIDHigh, sp.context.traceID.High)
+	bytes = appendProtoFixed64(bytes, spanTraceIDLow, sp.context.traceID.Low)
+	bytes = appendProtoFixed64(bytes, spanSpanID, sp.context.spanID)
+	bytes = appendProtoFixed64(bytes, spanParentID, sp.parentID)
+	for _, ref := range sp.references {
+		bytes = enc.encodeReference(bytes, ref)
+	}
+	bytes = appendProtoString(bytes, spanOperation, sp.operation)
+	start := sp.start
+	if start.IsZero() {
+		start = time.Now()
+	}
+	bytes = appendProtoVarint(bytes, spanStart, uint64(start.UnixNano()/1e3))
+	if !sp.finish.IsZero() {
+		bytes = appendProtoVarint(bytes, spanFinish, uint64(sp.finish.UnixNano()/1e3))
+	}
+	if sp.duration >= 0 {
+		bytes = appendProtoVarint(bytes, spanDuration, uint64(sp.duration.Nanoseconds()/1e3))
+	}
+	for k, v := range sp.tags {
+		bytes = enc.encodeTag(bytes, k, v)
+	}
+	for k, v := range sp.context.baggage {
+		m := len(bytes)
+		bytes = beginProtoMessage(bytes, spanBaggage)
+		bytes = appendProtoString(bytes, mapKey, k)
+		bytes = appendProtoString(bytes, mapValue, v)
+		bytes = endProtoMessage(bytes, m)
+	}
+	for _, lr := range sp.logs {
+		if !lr.Timestamp.IsZero() {
+			bytes = enc.encodeLog(bytes, lr)
+		}
+	}
+	bytes = appendProtoVarint(bytes, spanDroppedTags, uint64(sp.droppedTags))
+	bytes = appendProtoVarint(bytes, spanDroppedLogs, uint64(sp.droppedLogs))
+	bytes = appendProtoVarint(bytes, spanDroppedBaggage, uint64(sp.droppedBaggage))
+	bytes = appendProtoVarint(bytes, spanEvent, protoEvent(sp))
+
+	return endProtoLength(bytes, mark)
+}
+
+func (enc *protoEncoder) encodeReference(bytes []byte, ref spanReference) []byte {
+	mark := len(bytes)
+	bytes = beginProtoMessage(bytes, spanReferences)
+	if ref.refType == opentracing.FollowsFromRef {
+		bytes = appendProtoVarint(bytes, referenceType, 1)
+	}
+	bytes = appendProtoFixed64(bytes, referenceTraceIDHigh, ref.context.traceID.High)
+	bytes = appendProtoFixed64(bytes, referenceTraceIDLow, ref.context.traceID.Low)
+	bytes = appendProtoFixed64(bytes, referenceSpanID, ref.context.spanID)
+	return endProtoMessage(bytes, mark)
+}
+
+func (enc *protoEncoder) encodeTag(bytes []byte, key string, v interface{}) []byte {
+	mark := len(bytes)
+	bytes = beginProtoMessage(bytes, spanTags)
+	bytes = appendProtoString(bytes, mapKey, key)
+	bytes = enc.encodeValue(bytes, mapValue, v)
+	return endProtoMessage(bytes, mark)
+}
+
+// encodeValue appends v as a Value message.  Strings, booleans, and numbers
+// keep their types, and other values are encoded as JSON.
+func (enc *protoEncoder) encodeValue(bytes []byte, field int, v interface{}) []byte {
+	mark := len(bytes)
+	bytes = beginProtoMessage(bytes, field)
+	switch tval := v.(type) {
+	case string:
+		bytes = appendProtoString(bytes, valueString, tval)
+	case bool:
+		bytes = appendProtoBool(bytes, valueBool, tval)
+	case int:
+		bytes = appendProtoSint(bytes, valueInt, int64(tval))
+	case int8:
+		bytes = appendProtoSint(bytes, valueInt, int64(tval))
+	case int16:
+		bytes = appendProtoSint(bytes, valueInt, int64(tval))
+	case int32:
+		bytes = appendProtoSint(bytes, valueInt, int64(tval))
+	case int64:
+		bytes = appendProtoSint(bytes, valueInt, tval)
+	case uint:
+		bytes = appendProtoUint(bytes, valueUint, uint64(tval))
+	case uint8:
+		bytes = appendProtoUint(bytes, valueUint, uint64(tval))
+	case uint16:
+		bytes = appendProtoUint(bytes, valueUint, uint64(tval))
+	case uint32:
+		bytes = appendProtoUint(bytes, valueUint, uint64(tval))
+	case uint64:
+		bytes = appendProtoUint(bytes, valueUint, tval)
+	case float32:
+		bytes = appendProtoDouble(bytes, valueDouble, float64(tval))
+	case float64:
+		bytes = appendProtoDouble(bytes, valueDouble, tval)
+	default:
+		json := enc.json.encodeValue(make([]byte, 0, 64), v, 0, maxValueSize)
+		bytes = appendProtoTag(bytes, valueJSON, protoBytes)
+		bytes = appendUvarint(bytes, uint64(len(json)))
+		bytes = append(bytes, json...)
+	}
+	return endProtoMessage(bytes, mark)
+}
+
+func (enc *protoEncoder) encodeLog(bytes []byte, lr opentracing.LogRecord) []byte {
+	mark := len(bytes)
+	bytes = beginProtoMessage(bytes, spanLogs)
+	bytes = appendProtoVarint(bytes, logTimestamp, uint64(lr.Timestamp.UnixNano()/1e3))
+	fe := protoFieldEncoder{enc: enc, bytes: bytes}
+	for _, f := range lr.Fields {
+		marshalField(f, &fe)
+	}
+	return endProtoMessage(fe.bytes, mark)
+}
+
+// protoEvent returns the Span.Event value of the event sp was reported for.
+func protoEvent(sp *SpanData) uint64 {
+	if sp.duration >= 0 {
+		return protoFinishSpan
+	}
+	if len(sp.logs) == 1 && len(sp.logs[0].Fields) > 0 {
+		f := sp.logs[0].Fields[0]
+		if f.Key() == "event" && f.Value() == "Start-Span" {
+			return protoStartSpan
+		}
+	}
+	return protoLog
+}
+
+// protoFieldEncoder is a log.Encoder appending log fields as Field messages,
+// keeping the kind of each field like logFieldEncoder does for JSON.
+type protoFieldEncoder struct {
+	enc   *protoEncoder
+	bytes []byte
+}
+
+func (e *protoFieldEncoder) emit(key string, value interface{}) {
+	mark := len(e.bytes)
+	e.bytes = beginProtoMessage(e.bytes, logFields)
+	e.bytes = appendProtoString(e.bytes, fieldKey, key)
+	e.bytes = e.enc.encodeValue(e.bytes, fieldValue, value)
+	e.bytes = endProtoMessage(e.bytes, mark)
+}
+
+func (e *protoFieldEncoder) EmitString(key, value string)          { e.emit(key, value) }
+func (e *protoFieldEncoder) EmitBool(key string, value bool)       { e.emit(key, value) }
+func (e *protoFieldEncoder) EmitInt(key string, value int)         { e.emit(key, value) }
+func (e *protoFieldEncoder) EmitInt32(key string, value int32)     { e.emit(key, value) }
+func (e *protoFieldEncoder) EmitInt64(key string, value int64)     { e.emit(key, value) }
+func (e *protoFieldEncoder) EmitUint32(key string, value uint32)   { e.emit(key, value) }
+func (e *protoFieldEncoder) EmitUint64(key string, value uint64)   { e.emit(key, value) }
+func (e *protoFieldEncoder) EmitFloat32(key string, value float32) { e.emit(key, value) }
+func (e *protoFieldEncoder) EmitFloat64(key string, value float64) { e.emit(key, value) }
+
+func (e *protoFieldEncoder) EmitObject(key string, value interface{}) {
+	e.emit(key, value)
+}
+
+func (e *protoFieldEncoder) EmitLazyLogger(value log.LazyLogger) {
+	if value != nil {
+		value(e)
+	}
+}
+
+func appendProtoTag(bytes []byte, field int, wireType int) []byte {
+	return appendUvarint(bytes, uint64(field)<<3|uint64(wireType))
+}
+
+func appendUvarint(bytes []byte, v uint64) []byte {
+	for v >= 0x80 {
+		bytes = append(bytes, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(bytes, byte(v))
+}
+
+// The appendProto* functions append a field, omitting zero values as proto3
+// does, except within Value messages where the field tells the type.
+
+func appendProtoVarint(bytes []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return bytes
+	}
+	return appendProtoUint(bytes, field, v)
+}
+
+func appendProtoUint(bytes []byte, field int, v uint64) []byte {
+	bytes = appendProtoTag(bytes, field, protoVarint)
+	return appendUvarint(bytes, v)
+}
+
+func appendProtoSint(bytes []byte, field int, v int64) []byte {
+	bytes = appendProtoTag(bytes, field, protoVarint)
+	return appendUvarint(bytes, uint64(v<<1)^uint64(v>>63))
+}
+
+func appendProtoBool(bytes []byte, field int, v bool) []byte {
+	bytes = appendProtoTag(bytes, field, protoVarint)
+	if v {
+		return append(bytes, 1)
+	}
+	return append(bytes, 0)
+}
+
+func appendProtoDouble(bytes []byte, field int, v float64) []byte {
+	bytes = appendProtoTag(bytes, field, protoFixed64)
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+	return append(bytes, b[:]...)
+}
+
+func appendProtoFixed64(bytes []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return bytes
+	}
+	bytes = appendProtoTag(bytes, field, protoFixed64)
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return append(bytes, b[:]...)
+}
+
+// appendProtoString appends a string field.  Invalid UTF-8 is replaced by
+// U+FFFD, as protobuf strings must be valid UTF-8.
+func appendProtoString(bytes []byte, field int, s string) []byte {
+	if s == "" {
+		return bytes
+	}
+	s = validKey(s)
+	bytes = appendProtoTag(bytes, field, protoBytes)
+	bytes = appendUvarint(bytes, uint64(len(s)))
+	return append(bytes, s...)
+}
+
+// beginProtoMessage appends the tag of an embedded message field and a byte
+// reserved for its length.  endProtoMessage is called with len(bytes) from
+// before the call once the fields of the message are appended.
+func beginProtoMessage(bytes []byte, field int) []byte {
+	bytes = appendProtoTag(bytes, field, protoBytes)
+	return append(bytes, 0)
+}
+
+// endProtoMessage sets the length of the message begun at mark.  Most
+// messages are shorter than 128 bytes, so their length fits the reserved
+// byte; longer ones are moved to make room.
+func endProtoMessage(bytes []byte, mark int) []byte {
+	i := mark
+	for bytes[i]&0x80 != 0 {
+		i++
+	}
+	return endProtoLength(bytes, i+1)
+}
+
+// beginProtoLength reserves a byte for the length prefix of an event.
+func beginProtoLength(bytes []byte) []byte {
+	return append(bytes, 0)
+}
+
+// endProtoLength sets the length of the data following the byte reserved at
+// mark, moving the data if the length needs more than one byte.
+func endProtoLength(bytes []byte, mark int) []byte {
+	n := len(bytes) - mark - 1
+	var b [binary.MaxVarintLen64]byte
+	size := binary.PutUvarint(b[:], uint64(n))
+	if size > 1 {
+		bytes = append(bytes, b[:size-1]...)
+		copy(bytes[mark+size:], bytes[mark+1:mark+1+n])
+	}
+	copy(bytes[mark:], b[:size])
+	return bytes
+}
@@ -0,0 +1,271 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// protoMessage maps the field numbers of a decoded message to their values:
+// uint64 for varint and fixed64 fields, and protoBytesValue for
+// length-delimited ones, which can be read as strings or nested messages.
+type protoMessage map[int][]interface{}
+
+type protoBytesValue []byte
+
+func decodeProto(b []byte) protoMessage {
+	m := protoMessage{}
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		Ω(n).Should(BeNumerically(">", 0), "tag")
+		b = b[n:]
+		field := int(tag >> 3)
+		switch tag & 7 {
+		case protoVarint:
+			v, n := binary.Uvarint(b)
+			Ω(n).Should(BeNumerically(">", 0), "varint")
+			m[field] = append(m[field], v)
+			b = b[n:]
+		case protoFixed64:
+			Ω(len(b)).Should(BeNumerically(">=", 8), "fixed64")
+			m[field] = append(m[field], binary.LittleEndian.Uint64(b))
+			b = b[8:]
+		case protoBytes:
+			l, n := binary.Uvarint(b)
+			Ω(n).Should(BeNumerically(">", 0), "length")
+			b = b[n:]
+			Ω(uint64(len(b))).Should(BeNumerically(">=", l), "bytes")
+			m[field] = append(m[field], protoBytesValue(b[:l]))
+			b = b[l:]
+		default:
+			Fail("unexpected wire type")
+		}
+	}
+	return m
+}
+
+// decodeDelimited decodes a length-delimited message, checking the length.
+func decodeDelimited(b []byte) protoMessage {
+	l, n := binary.Uvarint(b)
+	Ω(n).Should(BeNumerically(">", 0))
+	Ω(uint64(len(b) - n)).Should(Equal(l))
+	return decodeProto(b[n:])
+}
+
+func (m protoMessage) uint(field int) uint64 {
+	if len(m[field]) == 0 {
+		return 0
+	}
+	return m[field][0].(uint64)
+}
+
+func (m protoMessage) str(field int) string {
+	if len(m[field]) == 0 {
+		return ""
+	}
+	return string(m[field][0].(protoBytesValue))
+}
+
+func (m protoMessage) messages(field int) []protoMessage {
+	var ms []protoMessage
+	for _, v := range m[field] {
+		ms = append(ms, decodeProto(v.(protoBytesValue)))
+	}
+	return ms
+}
+
+// value decodes a Value message to a Go value.
+func (m protoMessage) value() interface{} {
+	switch {
+	case len(m[valueString]) > 0:
+		return m.str(valueString)
+	case len(m[valueBool]) > 0:
+		return m.uint(valueBool) != 0
+	case len(m[valueInt]) > 0:
+		u := m.uint(valueInt)
+		return int64(u>>1) ^ -int64(u&1)
+	case len(m[valueUint]) > 0:
+		return m.uint(valueUint)
+	case len(m[valueDouble]) > 0:
+		return math.Float64frombits(m.uint(valueDouble))
+	case len(m[valueJSON]) > 0:
+		return "json:" + m.str(valueJSON)
+	}
+	return nil
+}
+
+var _ = Describe("ProtobufSpanEncoder", func() {
+
+	var enc SpanEncoder
+
+	BeforeEach(func() {
+		enc = NewProtobufSpanEncoder()
+	})
+
+	It("encodes minimal span", func() {
+		sp := &SpanData{
+			operation: "op",
+			context:   spanContext{traceID: TraceID{Low: 123}, spanID: 456},
+			start:     time.Unix(1, 0),
+			duration:  -1,
+		}
+		Ω(enc.Encode(sp)).Should(Equal([]byte{
+			29,
+			0x11, 123, 0, 0, 0, 0, 0, 0, 0,
+			0x19, 0xc8, 1, 0, 0, 0, 0, 0, 0,
+			0x32, 2, 'o', 'p',
+			0x38, 0xc0, 0x84, 0x3d,
+			0x80, 1, 1,
+		}))
+	})
+
+	It("encodes full span", func() {
+		start := time.Unix(1500000000, 0)
+		sp := &SpanData{
+			operation: "op",
+			context: spanContext{
+				traceID: TraceID{High: 1, Low: 123},
+				spanID:  456,
+				baggage: map[string]string{"bkey": "bval"},
+			},
+			parentID: 789,
+			references: []spanReference{
+				{
+					refType: opentracing.ChildOfRef,
+					context: spanContext{traceID: TraceID{High: 1, Low: 123}, spanID: 789},
+				},
+				{
+					refType: opentracing.FollowsFromRef,
+					context: spanContext{traceID: TraceID{Low: 124}, spanID: 790},
+				},
+			},
+			start:    start,
+			finish:   start.Add(time.Millisecond),
+			duration: time.Millisecond,
+			tags: map[string]interface{}{
+				"str":   "val",
+				"bool":  true,
+				"int":   -3,
+				"uint":  uint32(4),
+				"float": 1.5,
+				"map":   map[string]int{"a": 1},
+			},
+			logs: []opentracing.LogRecord{
+				{Timestamp: start, Fields: []log.Field{log.String("event", "Start-Span")}},
+				{Timestamp: start, Fields: []log.Field{
+					log.String("event", "x"),
+					log.Int("n", 1),
+					log.Error(errors.New("boom")),
+					log.Lazy(func(fv log.Encoder) { fv.EmitFloat32("lazy", 0.5) }),
+				}},
+				{Timestamp: start.Add(time.Millisecond), Fields: []log.Field{log.String("event", "Finish-Span")}},
+			},
+			droppedTags: 2,
+		}
+
+		m := decodeDelimited(enc.Encode(sp))
+		Ω(m.uint(spanTraceIDHigh)).Should(Equal(uint64(1)))
+		Ω(m.uint(spanTraceIDLow)).Should(Equal(uint64(123)))
+		Ω(m.uint(spanSpanID)).Should(Equal(uint64(456)))
+		Ω(m.uint(spanParentID)).Should(Equal(uint64(789)))
+		Ω(m.str(spanOperation)).Should(Equal("op"))
+		Ω(m.uint(spanStart)).Should(Equal(uint64(1500000000000000)))
+		Ω(m.uint(spanFinish)).Should(Equal(uint64(1500000000001000)))
+		Ω(m.uint(spanDuration)).Should(Equal(uint64(1000)))
+		Ω(m.uint(spanDroppedTags)).Should(Equal(uint64(2)))
+		Ω(m.uint(spanEvent)).Should(Equal(uint64(protoFinishSpan)))
+
+		refs := m.messages(spanReferences)
+		Ω(refs).Should(HaveLen(2))
+		Ω(refs[0].uint(referenceType)).Should(Equal(uint64(0)))
+		Ω(refs[0].uint(referenceTraceIDHigh)).Should(Equal(uint64(1)))
+		Ω(refs[0].uint(referenceSpanID)).Should(Equal(uint64(789)))
+		Ω(refs[1].uint(referenceType)).Should(Equal(uint64(1)))
+		Ω(refs[1].uint(referenceTraceIDLow)).Should(Equal(uint64(124)))
+
+		tags := map[string]interface{}{}
+		for _, e := range m.messages(spanTags) {
+			tags[e.str(mapKey)] = e.messages(mapValue)[0].value()
+		}
+		Ω(tags).Should(Equal(map[string]interface{}{
+			"str":   "val",
+			"bool":  true,
+			"int":   int64(-3),
+			"uint":  uint64(4),
+			"float": 1.5,
+			"map":   `json:{"a":1}`,
+		}))
+
+		baggage := m.messages(spanBaggage)
+		Ω(baggage).Should(HaveLen(1))
+		Ω(baggage[0].str(mapKey)).Should(Equal("bkey"))
+		Ω(baggage[0].str(mapValue)).Should(Equal("bval"))
+
+		logs := m.messages(spanLogs)
+		Ω(logs).Should(HaveLen(3))
+		Ω(logs[0].uint(logTimestamp)).Should(Equal(uint64(1500000000000000)))
+		fields := logs[1].messages(logFields)
+		Ω(fields).Should(HaveLen(4))
+		var kv []interface{}
+		for _, f := range fields {
+			kv = append(kv, f.str(fieldKey), f.messages(fieldValue)[0].value())
+		}
+		Ω(kv).Should(Equal([]interface{}{
+			"event", "x", "n", int64(1), "error.object", "boom", "lazy", 0.5,
+		}))
+	})
+
+	It("tells Start-Span and Log events apart", func() {
+		sp := &SpanData{
+			operation: "op",
+			context:   spanContext{traceID: TraceID{Low: 1}, spanID: 1},
+			start:     time.Now(),
+			duration:  -1,
+			logs: []opentracing.LogRecord{
+				{Timestamp: time.Now(), Fields: []log.Field{log.String("event", "Start-Span")}},
+			},
+		}
+		Ω(decodeDelimited(enc.Encode(sp)).uint(spanEvent)).Should(Equal(uint64(protoStartSpan)))
+
+		sp.logs[0].Fields = []log.Field{log.String("event", "cache miss")}
+		Ω(decodeDelimited(enc.Encode(sp)).uint(spanEvent)).Should(Equal(uint64(protoLog)))
+	})
+
+	It("encodes long messages and invalid UTF-8", func() {
+		long := strings.Repeat("x", 300)
+		sp := &SpanData{
+			operation: "op\xff",
+			context:   spanContext{traceID: TraceID{Low: 1}, spanID: 1},
+			start:     time.Now(),
+			duration:  -1,
+			tags:      map[string]interface{}{"long": long},
+		}
+		bytes := enc.Encode(sp)
+		Ω(bytes[0] & 0x80).ShouldNot(BeZero())
+		m := decodeDelimited(bytes)
+		Ω(m.str(spanOperation)).Should(Equal("op�"))
+		tag := m.messages(spanTags)[0]
+		Ω(tag.str(mapKey)).Should(Equal("long"))
+		Ω(tag.messages(mapValue)[0].value()).Should(Equal(long))
+	})
+
+	It("writes consecutive events to a stream", func() {
+		sp := &SpanData{
+			operation: "op",
+			context:   spanContext{traceID: TraceID{Low: 1}, spanID: 1},
+			start:     time.Now(),
+			duration:  -1,
+		}
+		stream := append(enc.Encode(sp), enc.Encode(sp)...)
+		l, n := binary.Uvarint(stream)
+		Ω(decodeDelimited(stream[:n+int(l)]).str(spanOperation)).Should(Equal("op"))
+		Ω(decodeDelimited(stream[n+int(l):]).str(spanOperation)).Should(Equal("op"))
+	})
+})
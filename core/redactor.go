@@ -0,0 +1,367 @@
+package core
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// Redactor scrubs sensitive data from span tags, log fields, and baggage
+// before they are passed to the SpanReporter.  It only changes what is
+// reported; the Span itself and propagated baggage keep their values.
+type Redactor interface {
+	// Redact returns the value to report for key, and false if the key is to
+	// be removed altogether.
+	Redact(key string, value interface{}) (interface{}, bool)
+}
+
+// RedactorOptions configures a Redactor created via NewRedactor.  Key
+// matching is case-insensitive.  The rules are applied in the order of the
+// fields below, and only the first matching key rule applies.
+type RedactorOptions struct {
+	// DenyKeys lists the keys removed from tags, logs, and baggage.
+	DenyKeys []string
+
+	// HashKeys lists the keys whose values are replaced by a keyed hash, so
+	// that equal values can still be correlated without being revealed.
+	HashKeys []string
+
+	// HashKey is the secret used to hash values.  Without it, hashes of
+	// low-entropy values like email addresses can be reversed by guessing.
+	HashKey []byte
+
+	// URLKeys lists the keys holding URLs whose query parameters are
+	// stripped.  It defaults to "http.url".
+	URLKeys []string
+
+	// AllowQueryParams lists the query parameters kept in URLs.
+	AllowQueryParams []string
+
+	// Patterns are matched against all string values, and each match is
+	// replaced by Replacement.
+	Patterns []*regexp.Regexp
+
+	// Replacement replaces matches of Patterns.  It defaults to "[REDACTED]".
+	Replacement string
+}
+
+// Common patterns for use in RedactorOptions.Patterns.
+var (
+	// EmailPattern matches email addresses.
+	EmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+	// CardNumberPattern matches 13 to 19 digit payment card numbers, with
+	// optional space or dash separators.
+	CardNumberPattern = regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`)
+)
+
+type redactor struct {
+	deny        map[string]bool
+	hash        map[string]bool
+	hashKey     []byte
+	urls        map[string]bool
+	allowParams map[string]bool
+	patterns    []*regexp.Regexp
+	replacement string
+}
+
+// NewRedactor creates a Redactor applying the rules in opts.
+func NewRedactor(opts RedactorOptions) Redactor {
+	if opts.URLKeys == nil {
+		opts.URLKeys = []string{"http.url"}
+	}
+	if opts.Replacement == "" {
+		opts.Replacement = "[REDACTED]"
+	}
+	allowParams := make(map[string]bool, len(opts.AllowQueryParams))
+	for _, p := range opts.AllowQueryParams {
+		allowParams[p] = true
+	}
+	return &redactor{
+		deny:        keySet(opts.DenyKeys),
+		hash:        keySet(opts.HashKeys),
+		hashKey:     opts.HashKey,
+		urls:        keySet(opts.URLKeys),
+		allowParams: allowParams,
+		patterns:    opts.Patterns,
+		replacement: opts.Replacement,
+	}
+}
+
+func keySet(keys []string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		set[strings.ToLower(k)] = true
+	}
+	return set
+}
+
+func (r *redactor) Redact(key string, value interface{}) (interface{}, bool) {
+	v, keep, _ := r.redact(key, value, 0)
+	return v, keep
+}
+
+// redact redacts value under key, recursing into maps, slices, arrays, and
+// structs so their keys and strings are redacted too.  It also reports
+// whether the value changed, since redacted copies of containers cannot be
+// compared with the original.
+func (r *redactor) redact(key string, value interface{}, depth int) (interface{}, bool, bool) {
+	lkey := strings.ToLower(key)
+	if r.deny[lkey] {
+		return nil, false, true
+	}
+
+	if isNilPointer(value) {
+		// Calling its methods could panic, and it is encoded as null.
+		return value, true, false
+	}
+
+	var s string
+	switch tval := value.(type) {
+	case string:
+		s = tval
+	case json.Marshaler:
+		return r.redactMarshaler(key, tval, depth)
+	case error:
+		s = tval.Error()
+	case fmt.Stringer:
+		s = tval.String()
+	default:
+		v, changed := r.redactNested(key, value, depth)
+		return v, true, changed
+	}
+	if rs := r.redactString(lkey, s); rs != s {
+		return rs, true, true
+	}
+	return value, true, false
+}
+
+// redactMarshaler redacts the JSON output of m, which is what the encoders
+// report, and returns it decoded if anything in it changed.
+func (r *redactor) redactMarshaler(
+	key string,
+	m json.Marshaler,
+	depth int,
+) (interface{}, bool, bool) {
+	b, err := m.MarshalJSON()
+	if err != nil {
+		return m, true, false
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var decoded interface{}
+	if err := dec.Decode(&decoded); err != nil {
+		return m, true, false
+	}
+	if v, keep, changed := r.redact(key, decoded, depth); changed {
+		return v, keep, true
+	}
+	return m, true, false
+}
+
+func (r *redactor) redactString(lkey, s string) string {
+	switch {
+	case r.hash[lkey]:
+		return r.hashValue(s)
+	case r.urls[lkey]:
+		s = r.stripQuery(s)
+	}
+	for _, p := range r.patterns {
+		s = p.ReplaceAllLiteralString(s, r.replacement)
+	}
+	return s
+}
+
+// redactNested returns a redacted copy of a container value, as a
+// map[string]interface{} or an []interface{}, if anything in it changed.
+// Elements of slices and arrays are redacted under the key of the slice.
+func (r *redactor) redactNested(key string, value interface{}, depth int) (interface{}, bool) {
+	return rewriteNested(key, value, depth, r.redact)
+}
+
+// rewriteNested applies rewrite to the elements of a container value, under
+// their map key or struct field name, and returns a copy of the container as
+// a map[string]interface{} or an []interface{} if any of them changed or was
+// removed.  Elements of slices and arrays are rewritten under key, and
+// containers nested deeper than maxValueDepth are replaced by "...".
+func rewriteNested(
+	key string,
+	value interface{},
+	depth int,
+	rewrite func(key string, value interface{}, depth int) (interface{}, bool, bool),
+) (interface{}, bool) {
+	rv := reflect.ValueOf(value)
+	if depth >= maxValueDepth {
+		switch rv.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+			// Too deep to check, so it is cut off as the encoders would.
+			return "...", true
+		}
+	}
+	switch rv.Kind() {
+	case reflect.String:
+		if v, _, changed := rewrite(key, rv.String(), depth); changed {
+			return v, true
+		}
+	case reflect.Ptr, reflect.Interface:
+		if !rv.IsNil() && rv.Elem().CanInterface() {
+			return rewriteNested(key, rv.Elem().Interface(), depth+1, rewrite)
+		}
+	case reflect.Map:
+		out := make(map[string]interface{}, rv.Len())
+		changed := false
+		for _, k := range rv.MapKeys() {
+			name := fmt.Sprint(k.Interface())
+			v, keep, c := rewrite(name, rv.MapIndex(k).Interface(), depth+1)
+			if keep {
+				out[name] = v
+			}
+			changed = changed || c
+		}
+		if changed {
+			return out, true
+		}
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		out := make([]interface{}, 0, rv.Len())
+		changed := false
+		for i := 0; i < rv.Len(); i++ {
+			v, keep, c := rewrite(key, rv.Index(i).Interface(), depth+1)
+			if keep {
+				out = append(out, v)
+			}
+			changed = changed || c
+		}
+		if changed {
+			return out, true
+		}
+	case reflect.Struct:
+		t := rv.Type()
+		out := make(map[string]interface{}, t.NumField())
+		changed := false
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name := f.Name
+			if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			}
+			v, keep, c := rewrite(name, rv.Field(i).Interface(), depth+1)
+			if keep {
+				out[name] = v
+			}
+			changed = changed || c
+		}
+		if changed {
+			return out, true
+		}
+	}
+	return value, false
+}
+
+func (r *redactor) hashValue(s string) string {
+	mac := hmac.New(sha256.New, r.hashKey)
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+func (r *redactor) stripQuery(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		// Fail closed: the query cannot be filtered, so drop all of it.
+		if i := strings.IndexByte(s, '?'); i >= 0 {
+			return s[:i]
+		}
+		return s
+	}
+	if u.RawQuery == "" {
+		return s
+	}
+	q := u.Query()
+	for p := range q {
+		if !r.allowParams[p] {
+			q.Del(p)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// redactTags returns a redacted copy of tags.
+func redactTags(r Redactor, tags map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(tags))
+	for k, v := range tags {
+		if v, ok := r.Redact(k, v); ok {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// redactLog returns a copy of lr with redacted fields.  Fields whose value
+// changes are replaced with string fields, or object fields for redacted
+// containers.  Lazy fields are expanded first, so the fields they emit are
+// redacted too.
+func redactLog(r Redactor, lr opentracing.LogRecord) opentracing.LogRecord {
+	fields := make([]log.Field, 0, len(lr.Fields))
+	for _, f := range expandLazyFields(lr.Fields) {
+		v, ok := r.Redact(f.Key(), f.Value())
+		if !ok {
+			continue
+		}
+		if !sameValue(v, f.Value()) {
+			if s, isString := v.(string); isString {
+				f = log.String(f.Key(), s)
+			} else {
+				f = log.Object(f.Key(), v)
+			}
+		}
+		fields = append(fields, f)
+	}
+	lr.Fields = fields
+	return lr
+}
+
+// isNilPointer tells whether v is a typed nil pointer, whose methods may
+// panic when called through an interface.
+func isNilPointer(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// sameValue tells whether a and b are known to be equal, without panicking on
+// values which cannot be compared.
+func sameValue(a, b interface{}) bool {
+	t := reflect.TypeOf(a)
+	return t == reflect.TypeOf(b) && (t == nil || t.Comparable()) && a == b
+}
+
+// redactBaggage returns a redacted copy of baggage.  Non-string results are
+// dropped, since baggage values are strings.
+func redactBaggage(r Redactor, baggage map[string]string) map[string]string {
+	out := make(map[string]string, len(baggage))
+	for k, v := range baggage {
+		if v, ok := r.Redact(k, v); ok {
+			if s, isString := v.(string); isString {
+				out[k] = s
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,154 @@
+package core_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"regexp"
+
+	"github.com/Nordstrom/ctrace-go/core"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// jsonCustomer marshals itself with lower-case keys.
+type jsonCustomer struct {
+	Email  string
+	Orders int
+}
+
+func (c jsonCustomer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{"email": c.Email, "orders": c.Orders})
+}
+
+var _ = Describe("Redactor", func() {
+	var r core.Redactor
+
+	BeforeEach(func() {
+		r = core.NewRedactor(core.RedactorOptions{
+			DenyKeys:         []string{"Password"},
+			HashKeys:         []string{"user.id"},
+			HashKey:          []byte("secret"),
+			AllowQueryParams: []string{"page"},
+			Patterns:         []*regexp.Regexp{core.EmailPattern, core.CardNumberPattern},
+		})
+	})
+
+	It("removes denied keys", func() {
+		_, ok := r.Redact("password", "hunter2")
+		Ω(ok).Should(BeFalse())
+	})
+
+	It("hashes values", func() {
+		v1, _ := r.Redact("user.id", "alice")
+		v2, _ := r.Redact("user.id", "alice")
+		v3, _ := r.Redact("user.id", "bob")
+		Ω(v1).Should(MatchRegexp(`^[0-9a-f]{32}$`))
+		Ω(v1).Should(Equal(v2))
+		Ω(v1).ShouldNot(Equal(v3))
+	})
+
+	It("strips query parameters", func() {
+		v, _ := r.Redact("http.url", "https://host/orders?page=2&email=a@b.com&token=t")
+		Ω(v).Should(Equal("https://host/orders?page=2"))
+	})
+
+	It("scrubs patterns", func() {
+		v, _ := r.Redact("message", "mail bob@example.com, card 4111 1111 1111 1111")
+		Ω(v).Should(Equal("mail [REDACTED], card [REDACTED]"))
+	})
+
+	It("drops the query of unparsable URLs", func() {
+		v, _ := r.Redact("http.url", "https://host/%zz?email=a@b.com")
+		Ω(v).Should(Equal("https://host/%zz"))
+	})
+
+	It("redacts nested values", func() {
+		type login struct {
+			User     string `json:"user"`
+			Password string
+			Cards    []string
+		}
+		v, ok := r.Redact("request", map[string]interface{}{
+			"login": &login{User: "bob@example.com", Password: "hunter2", Cards: []string{"4111111111111111"}},
+			"count": 2,
+		})
+		Ω(ok).Should(BeTrue())
+		Ω(v).Should(Equal(map[string]interface{}{
+			"login": map[string]interface{}{"user": "[REDACTED]", "Cards": []interface{}{"[REDACTED]"}},
+			"count": 2,
+		}))
+
+		unchanged := []int{1, 2}
+		v, _ = r.Redact("ids", unchanged)
+		Ω(v).Should(Equal(unchanged))
+	})
+
+	It("scrubs errors and Stringers", func() {
+		v, _ := r.Redact("error", errors.New("no user bob@example.com"))
+		Ω(v).Should(Equal("no user [REDACTED]"))
+
+		err := errors.New("not found")
+		v, _ = r.Redact("error", err)
+		Ω(v).Should(Equal(err))
+	})
+
+	It("redacts the JSON of Marshalers", func() {
+		v, ok := r.Redact("customer", jsonCustomer{Email: "bob@example.com", Orders: 3})
+		Ω(ok).Should(BeTrue())
+		Ω(v).Should(Equal(map[string]interface{}{
+			"email":  "[REDACTED]",
+			"orders": json.Number("3"),
+		}))
+
+		clean := jsonCustomer{Email: "unknown", Orders: 3}
+		v, _ = r.Redact("customer", clean)
+		Ω(v).Should(Equal(clean))
+	})
+
+	It("keeps typed nil pointers", func() {
+		var u *url.URL
+		v, ok := r.Redact("http.url", u)
+		Ω(ok).Should(BeTrue())
+		Ω(v).Should(BeNil())
+
+		var err *os.PathError
+		v, _ = r.Redact("error", err)
+		Ω(v).Should(Equal(err))
+	})
+
+	It("keeps other values", func() {
+		v, ok := r.Redact("http.status_code", 200)
+		Ω(ok).Should(BeTrue())
+		Ω(v).Should(Equal(200))
+	})
+
+	Context("in a Tracer", func() {
+		It("redacts tags, logs, and baggage", func() {
+			var buf core.Buffer
+			trc := core.NewWithOptions(core.TracerOptions{Writer: &buf, Redactor: r})
+			sp := trc.StartSpan("op",
+				opentracing.Tag{Key: "http.url", Value: "/orders?email=a@b.com"},
+				opentracing.Tag{Key: "password", Value: "hunter2"},
+			)
+			sp.SetBaggageItem("email", "bob@example.com")
+			sp.LogFields(log.String("message", "bob@example.com"), log.Lazy(func(fe log.Encoder) {
+				fe.EmitString("password", "hunter2")
+			}), log.Object("user", map[string]string{"email": "bob@example.com", "password": "x"}))
+			Ω(sp.BaggageItem("email")).Should(Equal("bob@example.com"))
+			sp.Finish()
+
+			out := buf.Spans()[0]
+			Ω(out.Tags).Should(HaveKeyWithValue("http.url", "/orders"))
+			Ω(out.Tags).ShouldNot(HaveKey("password"))
+			Ω(out.Baggage).Should(Equal(map[string]string{"email": "[REDACTED]"}))
+			Ω(out.Logs[1]).Should(HaveKeyWithValue("message", "[REDACTED]"))
+			Ω(out.Logs[1]).ShouldNot(HaveKey("password"))
+			Ω(out.Logs[1]).Should(HaveKeyWithValue("user", map[string]interface{}{"email": "[REDACTED]"}))
+		})
+	})
+})
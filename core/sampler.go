@@ -0,0 +1,128 @@
+package core
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Sampler decides whether a new trace is sampled.  It is consulted for root
+// Spans, and for Spans whose remote parent left the decision to the receiver
+// (an X-Ray header with a missing or "?" Sampled field, or B3 headers without
+// a sampling flag).  Other child Spans inherit the decision of their parent,
+// whether that parent is local or was extracted from a remote process.
+type Sampler interface {
+	// IsSampled returns true if the trace starting with a Span named
+	// `operation` should be reported.
+	IsSampled(traceID TraceID, operation string) bool
+}
+
+// constSampler always makes the same decision.
+type constSampler struct {
+	decision bool
+}
+
+// NewConstSampler creates a Sampler that always returns the given decision.
+func NewConstSampler(sample bool) Sampler {
+	return &constSampler{decision: sample}
+}
+
+func (s *constSampler) IsSampled(traceID TraceID, operation string) bool {
+	return s.decision
+}
+
+// maxRandomNumber is the upper bound of the IDs generated by tracer.randomID.
+const maxRandomNumber = ^(uint64(1) << 63)
+
+// probabilisticSampler samples a fixed fraction of all traces.
+type probabilisticSampler struct {
+	boundary uint64
+}
+
+// NewProbabilisticSampler creates a Sampler that samples the given fraction
+// (0.0 to 1.0) of traces.  The decision is derived from the low 64 bits of the
+// trace ID, so every process using the same rate makes the same decision for a
+// given trace.
+func NewProbabilisticSampler(rate float64) Sampler {
+	rate = math.Max(0.0, math.Min(rate, 1.0))
+	return &probabilisticSampler{
+		boundary: uint64(float64(maxRandomNumber) * rate),
+	}
+}
+
+func (s *probabilisticSampler) IsSampled(traceID TraceID, operation string) bool {
+	return traceID.Low&maxRandomNumber < s.boundary
+}
+
+// rateLimitingSampler samples at most a fixed number of traces per second
+// using a token bucket.
+type rateLimitingSampler struct {
+	sync.Mutex
+	creditsPerSecond float64
+	balance          float64
+	maxBalance       float64
+	lastTick         time.Time
+	now              func() time.Time
+}
+
+// NewRateLimitingSampler creates a Sampler that samples up to spansPerSecond
+// new traces per second.  Bursts of up to max(1, spansPerSecond) traces are
+// allowed.
+func NewRateLimitingSampler(spansPerSecond float64) Sampler {
+	return newRateLimitingSampler(spansPerSecond, time.Now)
+}
+
+func newRateLimitingSampler(spansPerSecond float64, now func() time.Time) *rateLimitingSampler {
+	maxBalance := math.Max(spansPerSecond, 1.0)
+	return &rateLimitingSampler{
+		creditsPerSecond: spansPerSecond,
+		balance:          maxBalance,
+		maxBalance:       maxBalance,
+		lastTick:         now(),
+		now:              now,
+	}
+}
+
+func (s *rateLimitingSampler) IsSampled(traceID TraceID, operation string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	now := s.now()
+	s.balance += now.Sub(s.lastTick).Seconds() * s.creditsPerSecond
+	s.lastTick = now
+	if s.balance > s.maxBalance {
+		s.balance = s.maxBalance
+	}
+	if s.balance >= 1.0 {
+		s.balance--
+		return true
+	}
+	return false
+}
+
+// operationSampler delegates to a Sampler chosen by operation name.
+type operationSampler struct {
+	samplers       map[string]Sampler
+	defaultSampler Sampler
+}
+
+// NewOperationSampler creates a Sampler that delegates to the Sampler
+// registered for the operation name of the root Span, falling back to
+// defaultSampler for operations that are not in the map.
+func NewOperationSampler(defaultSampler Sampler, samplers map[string]Sampler) Sampler {
+	s := make(map[string]Sampler, len(samplers))
+	for op, smp := range samplers {
+		s[op] = smp
+	}
+	return &operationSampler{
+		samplers:       s,
+		defaultSampler: defaultSampler,
+	}
+}
+
+func (s *operationSampler) IsSampled(traceID TraceID, operation string) bool {
+	if smp, ok := s.samplers[operation]; ok {
+		return smp.IsSampled(traceID, operation)
+	}
+	return s.defaultSampler.IsSampled(traceID, operation)
+}
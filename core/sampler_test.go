@@ -0,0 +1,87 @@
+package core
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sampler", func() {
+	Describe("ConstSampler", func() {
+		It("always samples", func() {
+			s := NewConstSampler(true)
+			Ω(s.IsSampled(TraceID{Low: 1}, "op")).Should(BeTrue())
+			Ω(s.IsSampled(TraceID{Low: maxRandomNumber}, "op")).Should(BeTrue())
+		})
+
+		It("never samples", func() {
+			s := NewConstSampler(false)
+			Ω(s.IsSampled(TraceID{Low: 1}, "op")).Should(BeFalse())
+			Ω(s.IsSampled(TraceID{Low: maxRandomNumber}, "op")).Should(BeFalse())
+		})
+	})
+
+	Describe("ProbabilisticSampler", func() {
+		It("samples trace IDs below the boundary", func() {
+			s := NewProbabilisticSampler(0.5)
+			Ω(s.IsSampled(TraceID{Low: 1}, "op")).Should(BeTrue())
+			Ω(s.IsSampled(TraceID{Low: maxRandomNumber/2 - 1}, "op")).Should(BeTrue())
+			Ω(s.IsSampled(TraceID{Low: maxRandomNumber/2 + 1}, "op")).Should(BeFalse())
+			Ω(s.IsSampled(TraceID{Low: maxRandomNumber}, "op")).Should(BeFalse())
+		})
+
+		It("samples nothing at rate 0", func() {
+			s := NewProbabilisticSampler(0)
+			Ω(s.IsSampled(TraceID{Low: 1}, "op")).Should(BeFalse())
+		})
+
+		It("clamps rates above 1", func() {
+			s := NewProbabilisticSampler(5)
+			Ω(s.IsSampled(TraceID{Low: maxRandomNumber - 1}, "op")).Should(BeTrue())
+		})
+	})
+
+	Describe("RateLimitingSampler", func() {
+		var (
+			now time.Time
+			s   Sampler
+		)
+
+		BeforeEach(func() {
+			now = time.Unix(1500000000, 0)
+			s = newRateLimitingSampler(2, func() time.Time { return now })
+		})
+
+		It("samples up to the rate", func() {
+			Ω(s.IsSampled(TraceID{Low: 1}, "op")).Should(BeTrue())
+			Ω(s.IsSampled(TraceID{Low: 2}, "op")).Should(BeTrue())
+			Ω(s.IsSampled(TraceID{Low: 3}, "op")).Should(BeFalse())
+		})
+
+		It("replenishes credits over time", func() {
+			s.IsSampled(TraceID{Low: 1}, "op")
+			s.IsSampled(TraceID{Low: 2}, "op")
+			now = now.Add(500 * time.Millisecond)
+			Ω(s.IsSampled(TraceID{Low: 3}, "op")).Should(BeTrue())
+			Ω(s.IsSampled(TraceID{Low: 4}, "op")).Should(BeFalse())
+		})
+
+		It("does not accumulate more than one second of credits", func() {
+			now = now.Add(time.Minute)
+			Ω(s.IsSampled(TraceID{Low: 1}, "op")).Should(BeTrue())
+			Ω(s.IsSampled(TraceID{Low: 2}, "op")).Should(BeTrue())
+			Ω(s.IsSampled(TraceID{Low: 3}, "op")).Should(BeFalse())
+		})
+	})
+
+	Describe("OperationSampler", func() {
+		It("delegates by operation name", func() {
+			s := NewOperationSampler(NewConstSampler(true), map[string]Sampler{
+				"health": NewConstSampler(false),
+			})
+			Ω(s.IsSampled(TraceID{Low: 1}, "health")).Should(BeFalse())
+			Ω(s.IsSampled(TraceID{Low: 1}, "other")).Should(BeTrue())
+		})
+	})
+})
@@ -1,7 +1,6 @@
 package core
 
 import (
-	"fmt"
 	"sync"
 	"time"
 
@@ -15,40 +14,91 @@ type SpanContext interface {
 	opentracing.SpanContext
 	TraceID() string
 	SpanID() string
+	RawTraceID() TraceID
+	RawSpanID() uint64
+	IsSampled() bool
+	IsDebug() bool
 	BaggageItem(key string) string
 }
 
 // spanContext holds the basic Span metadata.
 type spanContext struct {
 	// A probabilistically unique identifier for a [multi-span] trace.
-	traceID uint64
+	traceID TraceID
 
 	// A probabilistically unique identifier for a span.
 	spanID uint64
 
+	// Whether the trace is sampled, i.e. whether its Spans are reported.
+	sampled bool
+
+	// Whether the caller left the sampling decision to the receiver, so the
+	// Sampler decides as for a new trace when a Span is started from it.
+	samplingDeferred bool
+
+	// Whether the trace is force-sampled and reported in Multi-Event Mode
+	// regardless of the tracer configuration.
+	debug bool
+
+	// The W3C tracestate of the trace, passed on unchanged to child Spans.
+	traceState string
+
 	// The span's associated baggage.
 	baggage map[string]string // initialized on first use
+
+	// The baggage dropped by the BaggagePolicy when extracting the context,
+	// logged on the Spans started from it.
+	baggageViolations baggageViolations
 }
 
-// NewSpanContext creates a new SpanContext
+// NewSpanContext creates a new, sampled SpanContext with a 64-bit trace ID
 func NewSpanContext(
 	traceID uint64,
 	spanID uint64,
 	baggage map[string]string,
+) SpanContext {
+	return NewSpanContextFromTraceID(TraceID{Low: traceID}, spanID, baggage)
+}
+
+// NewSpanContextFromTraceID creates a new, sampled SpanContext
+func NewSpanContextFromTraceID(
+	traceID TraceID,
+	spanID uint64,
+	baggage map[string]string,
 ) SpanContext {
 	return spanContext{
 		traceID: traceID,
 		spanID:  spanID,
+		sampled: true,
 		baggage: baggage,
 	}
 }
 
 func (c spanContext) TraceID() string {
-	return fmt.Sprintf("%016x", c.traceID)
+	return c.traceID.String()
 }
 
 func (c spanContext) SpanID() string {
-	return fmt.Sprintf("%016x", c.spanID)
+	return formatSpanID(c.spanID)
+}
+
+func (c spanContext) RawTraceID() TraceID {
+	return c.traceID
+}
+
+func (c spanContext) RawSpanID() uint64 {
+	return c.spanID
+}
+
+// IsSampled tells whether the Spans of this trace are reported.
+func (c spanContext) IsSampled() bool {
+	return c.sampled
+}
+
+// IsDebug tells whether the trace is being debugged, in which case its Spans
+// are always reported in Multi-Event Mode.
+func (c spanContext) IsDebug() bool {
+	return c.debug
 }
 
 func (c spanContext) BaggageItem(key string) string {
@@ -71,7 +121,7 @@ func (c spanContext) ForeachBaggageItem(handler func(k, v string) bool) {
 // given key:value baggage pair set.
 func (c spanContext) WithBaggageItem(key, val string) spanContext {
 	if c.baggage == nil {
-		return spanContext{c.traceID, c.spanID, map[string]string{key: val}}
+		return spanContext{c.traceID, c.spanID, c.sampled, c.samplingDeferred, c.debug, c.traceState, map[string]string{key: val}, nil}
 	}
 	var newBaggage = make(map[string]string, len(c.baggage)+1)
 	for k, v := range c.baggage {
@@ -80,7 +130,32 @@ func (c spanContext) WithBaggageItem(key, val string) spanContext {
 	newBaggage[key] = val
 
 	// Use positional parameters so the compiler will help catch new fields.
-	return spanContext{c.traceID, c.spanID, newBaggage}
+	return spanContext{c.traceID, c.spanID, c.sampled, c.samplingDeferred, c.debug, c.traceState, newBaggage, nil}
+}
+
+// spanReference is a causal reference to another Span's context.
+type spanReference struct {
+	refType opentracing.SpanReferenceType
+	context spanContext
+}
+
+// linkContext is the SpanContext of a reference created by Link.
+type linkContext struct {
+	spanContext
+}
+
+// Link returns a SpanReference recording a FollowsFrom reference to ctx
+// without continuing its trace or inheriting its baggage or debug flag, e.g.
+// to link a new trace to the one claimed by an untrusted caller.
+func Link(ctx opentracing.SpanContext) opentracing.SpanReference {
+	sc, ok := ctx.(spanContext)
+	if !ok || !sc.traceID.IsValid() {
+		return opentracing.SpanReference{Type: opentracing.FollowsFromRef}
+	}
+	return opentracing.SpanReference{
+		Type:              opentracing.FollowsFromRef,
+		ReferencedContext: linkContext{spanContext{traceID: sc.traceID, spanID: sc.spanID}},
+	}
 }
 
 // Span represents an active, un-finished span in the OpenTracing system.
@@ -102,6 +177,9 @@ type span struct {
 	// "parent"), or 0 if there is no parent.
 	parentID uint64
 
+	// All ChildOf and FollowsFrom references the span was started with.
+	references []spanReference
+
 	// The name of the "operation" this span is an instance of. (Called a "span
 	// name" in some implementations)
 	operation string
@@ -118,7 +196,18 @@ type span struct {
 
 	logs []opentracing.LogRecord
 
-	prefix []byte
+	// The number of logs recorded, and of tags, logs, and baggage items
+	// dropped because of the TracerOptions limits.
+	numLogs        int
+	droppedTags    int
+	droppedLogs    int
+	droppedBaggage int
+
+	// The number of tags set by the tracer, which MaxTags does not count.
+	tracerTags int
+
+	// Whether a SpanProcessor filtered the span, so it is not reported.
+	filtered bool
 }
 
 func (s *span) SetOperationName(operationName string) opentracing.Span {
@@ -138,10 +227,40 @@ func (s *span) setTag(key string, value interface{}) opentracing.Span {
 	if s.tags == nil {
 		s.tags = make(map[string]interface{})
 	}
-	s.tags[key] = value
+	if s.tagLimitReached(key) {
+		s.droppedTags++
+		return s
+	}
+	s.tags[key] = s.limitValue(value)
 	return s
 }
 
+// setTracerTag sets a tag on behalf of the tracer, which is never dropped
+// because of MaxTags.
+func (s *span) setTracerTag(key string, value interface{}) {
+	if s.tags == nil {
+		s.tags = make(map[string]interface{})
+	}
+	if _, exists := s.tags[key]; !exists {
+		s.tracerTags++
+	}
+	s.tags[key] = s.limitValue(value)
+}
+
+// mergeBaggage copies baggage items into the span's own context.
+func (s *span) mergeBaggage(baggage map[string]string) {
+	for k, v := range baggage {
+		if s.baggageLimitReached(k, v) {
+			s.droppedBaggage++
+			continue
+		}
+		if s.context.baggage == nil {
+			s.context.baggage = make(map[string]string, len(baggage))
+		}
+		s.context.baggage[k] = v
+	}
+}
+
 func (s *span) LogKV(keyValues ...interface{}) {
 	fields, err := log.InterleavedKVToFields(keyValues...)
 	if err != nil {
@@ -161,12 +280,28 @@ func (s *span) LogFields(fields ...log.Field) {
 func (s *span) reportLog(l opentracing.LogRecord) {
 	s.Lock()
 	defer s.Unlock()
+	s.reportLogLocked(l)
+}
+
+func (s *span) reportLogLocked(l opentracing.LogRecord) {
+	if !s.context.sampled || s.filtered {
+		return
+	}
+	if s.logLimitReached() {
+		s.droppedLogs++
+		return
+	}
 	if l.Timestamp.IsZero() {
 		l.Timestamp = time.Now()
 	}
-	if s.tracer.options.MultiEvent {
+	l = s.limitLog(l)
+	if !s.processLog(l) {
+		return
+	}
+	s.numLogs++
+	if s.multiEvent() {
 		s.logs[0] = l
-		s.tracer.Report(s)
+		s.tracer.Report(s.snapshot())
 	} else {
 		s.logs = append(s.logs, l)
 	}
@@ -204,27 +339,29 @@ func (s *span) FinishWithOptions(opts opentracing.FinishOptions) {
 	defer s.Unlock()
 
 	for _, lr := range opts.LogRecords {
-		s.reportLog(lr)
+		s.reportLogLocked(lr)
 	}
 	for _, ld := range opts.BulkLogData {
-		s.reportLog(ld.ToLogRecord())
+		s.reportLogLocked(ld.ToLogRecord())
 	}
 
 	s.finish = finishTime
 	s.duration = duration
 
-	log := opentracing.LogRecord{
-		Timestamp: finishTime,
-		Fields:    []log.Field{log.String("event", "Finish-Span")},
-	}
+	if s.context.sampled && !s.filtered && s.processFinish() {
+		log := opentracing.LogRecord{
+			Timestamp: finishTime,
+			Fields:    []log.Field{log.String("event", "Finish-Span")},
+		}
 
-	if s.tracer.options.MultiEvent {
-		s.logs[0] = log
-	} else {
-		s.logs = append(s.logs, log)
-	}
+		if s.multiEvent() {
+			s.logs[0] = log
+		} else {
+			s.logs = append(s.logs, log)
+		}
 
-	s.tracer.Report(s)
+		s.tracer.Report(s.snapshot())
+	}
 	t := s.tracer
 	if s.tracer.options.DebugAssertUseAfterFinish {
 		// This makes it much more likely to catch a panic on any subsequent
@@ -236,6 +373,11 @@ func (s *span) FinishWithOptions(opts opentracing.FinishOptions) {
 	t.freeSpan(s)
 }
 
+// multiEvent tells whether this span reports each event as it happens.
+func (s *span) multiEvent() bool {
+	return s.tracer.options.MultiEvent || s.context.debug
+}
+
 func (s *span) Context() opentracing.SpanContext {
 	return s.context
 }
@@ -255,6 +397,18 @@ func (s *span) RawTracer() Tracer {
 func (s *span) SetBaggageItem(key, val string) opentracing.Span {
 	s.Lock()
 	defer s.Unlock()
+	if s.baggageLimitReached(key, val) {
+		s.droppedBaggage++
+		return s
+	}
+	if p := s.tracer.baggagePolicy; p != nil {
+		if reason := p.setItemReason(s.context.baggage, key, val); reason != "" {
+			for _, l := range (baggageViolations{reason: {key}}).logRecords() {
+				s.reportLogLocked(l)
+			}
+			return s
+		}
+	}
 	s.context = s.context.WithBaggageItem(key, val)
 	return s
 }
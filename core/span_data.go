@@ -0,0 +1,156 @@
+package core
+
+import (
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// SpanData is an immutable snapshot of a Span taken when one of its events is
+// reported.  It is what SpanReporters and SpanEncoders work with, so they can
+// be implemented outside of this package and can hold on to it after the Span
+// has moved on.  SpanData implements ReadOnlySpan.
+type SpanData struct {
+	context    spanContext
+	parentID   uint64
+	references []spanReference
+	operation  string
+	start      time.Time
+	finish     time.Time
+	duration   time.Duration
+	tags       map[string]interface{}
+	logs       []opentracing.LogRecord
+
+	droppedTags    int
+	droppedLogs    int
+	droppedBaggage int
+}
+
+// snapshot copies the mutable state of the span into a new SpanData, applying
+// the Redactor of the tracer if there is one.  It must be called with the span
+// locked.
+func (s *span) snapshot() *SpanData {
+	d := &SpanData{
+		context:    s.context,
+		parentID:   s.parentID,
+		references: s.references,
+		operation:  s.operation,
+		start:      s.start,
+		finish:     s.finish,
+		duration:   s.duration,
+
+		droppedTags:    s.droppedTags,
+		droppedLogs:    s.droppedLogs,
+		droppedBaggage: s.droppedBaggage,
+	}
+	r := s.tracer.options.Redactor
+	if len(s.tags) > 0 {
+		if r != nil {
+			d.tags = redactTags(r, s.tags)
+		} else {
+			d.tags = make(map[string]interface{}, len(s.tags))
+			for k, v := range s.tags {
+				d.tags[k] = v
+			}
+		}
+	}
+	if len(s.logs) > 0 {
+		d.logs = append(make([]opentracing.LogRecord, 0, len(s.logs)), s.logs...)
+		if r != nil {
+			for i, lr := range d.logs {
+				d.logs[i] = redactLog(r, lr)
+			}
+		}
+	}
+	if r != nil && len(s.context.baggage) > 0 {
+		d.context.baggage = redactBaggage(r, s.context.baggage)
+	}
+	return d
+}
+
+// Context returns the SpanContext of the Span, including its baggage.
+func (d *SpanData) Context() SpanContext {
+	return d.context
+}
+
+// ParentID returns the span ID of the parent Span, or 0 for a root Span.
+func (d *SpanData) ParentID() uint64 {
+	return d.parentID
+}
+
+// Operation returns the operation name of the Span.
+func (d *SpanData) Operation() string {
+	return d.operation
+}
+
+// StartTime returns the time the Span started.
+func (d *SpanData) StartTime() time.Time {
+	return d.start
+}
+
+// FinishTime returns the time the Span finished, or the zero time for events
+// reported before it finished.
+func (d *SpanData) FinishTime() time.Time {
+	return d.finish
+}
+
+// Duration returns the duration of the Span, or a negative value for events
+// reported before it finished.
+func (d *SpanData) Duration() time.Duration {
+	return d.duration
+}
+
+// Tag returns the value of the tag named key, or nil if it is not set.
+func (d *SpanData) Tag(key string) interface{} {
+	return d.tags[key]
+}
+
+// ForeachTag calls handler for each tag until it returns false.
+func (d *SpanData) ForeachTag(handler func(key string, value interface{}) bool) {
+	for k, v := range d.tags {
+		if !handler(k, v) {
+			break
+		}
+	}
+}
+
+// DroppedTags returns the number of tags dropped because of the MaxTags
+// option.
+func (d *SpanData) DroppedTags() int {
+	return d.droppedTags
+}
+
+// DroppedLogs returns the number of logs dropped because of the MaxLogs
+// option.
+func (d *SpanData) DroppedLogs() int {
+	return d.droppedLogs
+}
+
+// DroppedBaggage returns the number of baggage items dropped because of the
+// MaxBaggageBytes option.
+func (d *SpanData) DroppedBaggage() int {
+	return d.droppedBaggage
+}
+
+// ForeachLog calls handler for each log of the event until it returns false.
+// In Multi-Event Mode an event has a single log; in Single-Event Mode the
+// Finish-Span event has all logs of the Span.
+func (d *SpanData) ForeachLog(handler func(lr opentracing.LogRecord) bool) {
+	for _, lr := range d.logs {
+		if !handler(lr) {
+			break
+		}
+	}
+}
+
+// ForeachReference calls handler for each ChildOf and FollowsFrom reference
+// the Span was started with until it returns false.
+func (d *SpanData) ForeachReference(
+	handler func(refType opentracing.SpanReferenceType, ctx SpanContext) bool,
+) {
+	for _, ref := range d.references {
+		if !handler(ref.refType, ref.context) {
+			break
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package core_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Nordstrom/ctrace-go/core"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// textEncoder is a SpanEncoder written against the public SpanData API only.
+type textEncoder struct{}
+
+func (textEncoder) Encode(sp *core.SpanData) []byte {
+	var tags, logs, refs []string
+	sp.ForeachTag(func(k string, v interface{}) bool {
+		tags = append(tags, fmt.Sprintf("%s=%v", k, v))
+		return true
+	})
+	sort.Strings(tags)
+	sp.ForeachLog(func(lr opentracing.LogRecord) bool {
+		for _, f := range lr.Fields {
+			logs = append(logs, f.String())
+		}
+		return true
+	})
+	sp.ForeachReference(func(t opentracing.SpanReferenceType, c core.SpanContext) bool {
+		refs = append(refs, c.SpanID())
+		return true
+	})
+	return []byte(fmt.Sprintf("%s %s %x %v %s %s %s finished=%v\n",
+		sp.Context().TraceID(), sp.Operation(), sp.ParentID(), refs,
+		strings.Join(tags, ","), strings.Join(logs, ","),
+		sp.Context().BaggageItem("bag"), sp.Duration() >= 0))
+}
+
+// recordingReporter keeps every reported SpanData.
+type recordingReporter struct {
+	reported *[]*core.SpanData
+}
+
+func (r recordingReporter) Report(sp *core.SpanData) {
+	*r.reported = append(*r.reported, sp)
+}
+
+func (r recordingReporter) Flush(ctx context.Context) error { return nil }
+
+func (r recordingReporter) Close(ctx context.Context) error { return nil }
+
+var _ = Describe("SpanData", func() {
+	It("supports custom encoders", func() {
+		var buf bytes.Buffer
+		trc := core.NewWithOptions(core.TracerOptions{
+			Reporter:    core.NewSpanReporter(&buf, textEncoder{}),
+			ServiceName: "svc",
+		})
+		parent := core.NewSpanContext(1, 2, map[string]string{"bag": "bagval"})
+		sp := trc.StartSpan("op", opentracing.ChildOf(parent), opentracing.Tag{Key: "k", Value: 1})
+		sp.LogFields(log.String("event", "e"))
+		sp.Finish()
+
+		Ω(buf.String()).Should(Equal(
+			"0000000000000001 op 2 [0000000000000002] k=1,service=svc " +
+				"event:Start-Span,event:e,event:Finish-Span bagval finished=true\n"))
+	})
+
+	It("is not changed by later events", func() {
+		var reported []*core.SpanData
+		trc := core.NewWithOptions(core.TracerOptions{
+			MultiEvent: true,
+			Reporter:   recordingReporter{&reported},
+		})
+		sp := trc.StartSpan("op")
+		sp.SetTag("k", "v")
+		sp.Finish()
+
+		Ω(reported).Should(HaveLen(2))
+		Ω(reported[0].Tag("k")).Should(BeNil())
+		Ω(reported[0].Duration()).Should(BeNumerically("<", 0))
+		Ω(reported[1].Tag("k")).Should(Equal("v"))
+		Ω(reported[1].Duration()).Should(BeNumerically(">=", 0))
+	})
+})
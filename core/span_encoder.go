@@ -2,6 +2,7 @@ package core
 
 import (
 	"time"
+	"unicode/utf8"
 
 	opentracing "github.com/opentracing/opentracing-go"
 )
@@ -13,11 +14,10 @@ const (
 
 // SpanEncoder is a format-agnostic interface for encoding span events.
 type SpanEncoder interface {
-	//Close()
-
-	// Return the encoder to the appropriate sync.Pool. Unpooled encoder
-	// implementations can no-op this method.
-	Encode(opentracing.Span) []byte
+	// Encode serializes a span event, including the trailing delimiter if the
+	// format needs one.  It may be called concurrently and after the Span has
+	// finished.
+	Encode(*SpanData) []byte
 }
 
 // spanEncoder is an Encoder implementation that writes JSON.
@@ -30,21 +30,38 @@ func NewSpanEncoder() SpanEncoder {
 	return &spanEncoder{jsonEncoder: jsonEncoder{}}
 }
 
-func (enc *spanEncoder) Encode(osp opentracing.Span) []byte {
-	sp := osp.(*span)
+func (enc *spanEncoder) Encode(sp *SpanData) []byte {
 	bytes := make([]byte, 0, 1024)
 
-	if sp.prefix == nil || len(sp.prefix) <= 0 {
-		enc.encodePrefix(sp)
+	bytes = append(bytes, '{')
+	bytes = enc.encodeKeyTraceID(bytes, "traceId", sp.context.traceID)
+	bytes = enc.encodeKeyID(bytes, "spanId", sp.context.spanID)
+	if sp.parentID > 0 {
+		bytes = enc.encodeKeyID(bytes, "parentId", sp.parentID)
 	}
+	bytes = enc.encodeReferences(bytes, sp.references)
+	bytes = enc.encodeKeyValue(bytes, "operation", sp.operation)
+	start := sp.start
+	if start.IsZero() {
+		start = time.Now()
+	}
+	bytes = enc.encodeKeyInt(bytes, "start", start.UnixNano()/1e3)
 
-	bytes = append(bytes, sp.prefix...)
 	if !sp.finish.IsZero() {
 		bytes = enc.encodeKeyInt(bytes, "finish", sp.finish.UnixNano()/1e3)
 	}
 	if sp.duration >= 0 {
 		bytes = enc.encodeKeyInt(bytes, "duration", sp.duration.Nanoseconds()/1e3)
 	}
+	if sp.droppedTags > 0 {
+		bytes = enc.encodeKeyInt(bytes, "droppedTags", int64(sp.droppedTags))
+	}
+	if sp.droppedLogs > 0 {
+		bytes = enc.encodeKeyInt(bytes, "droppedLogs", int64(sp.droppedLogs))
+	}
+	if sp.droppedBaggage > 0 {
+		bytes = enc.encodeKeyInt(bytes, "droppedBaggage", int64(sp.droppedBaggage))
+	}
 	bytes = enc.encodeTags(bytes, sp.tags)
 	bytes = enc.encodeBaggage(bytes, sp.context.baggage)
 	bytes = enc.encodeLogs(bytes, sp.logs)
@@ -53,23 +70,31 @@ func (enc *spanEncoder) Encode(osp opentracing.Span) []byte {
 	return bytes
 }
 
-func (enc *spanEncoder) encodePrefix(sp *span) {
-	if sp.prefix == nil {
-		sp.prefix = make([]byte, 0, 512)
-	}
-	sp.prefix = append(sp.prefix, '{')
-	sp.prefix = enc.encodeKeyString(sp.prefix, "traceId", sp.context.TraceID())
-	sp.prefix = enc.encodeKeyString(sp.prefix, "spanId", sp.context.SpanID())
-
-	if sp.parentID > 0 {
-		sp.prefix = enc.encodeKeyID(sp.prefix, "parentId", sp.parentID)
+func (enc *spanEncoder) encodeReferences(bytes []byte, refs []spanReference) []byte {
+	if len(refs) <= 0 {
+		return bytes
 	}
-
-	sp.prefix = enc.encodeKeyValue(sp.prefix, "operation", sp.operation)
-	if sp.start.IsZero() {
-		sp.start = time.Now()
+	bytes = enc.encodeKey(bytes, "references")
+	bytes = append(bytes, '[')
+	for i, ref := range refs {
+		if i > 0 {
+			bytes = append(bytes, ',')
+		}
+		bytes = append(bytes, '{')
+		switch ref.refType {
+		case opentracing.ChildOfRef:
+			bytes = enc.encodeKeyString(bytes, "type", "child_of")
+		case opentracing.FollowsFromRef:
+			bytes = enc.encodeKeyString(bytes, "type", "follows_from")
+		default:
+			bytes = enc.encodeKeyInt(bytes, "type", int64(ref.refType))
+		}
+		bytes = enc.encodeKeyTraceID(bytes, "traceId", ref.context.traceID)
+		bytes = enc.encodeKeyID(bytes, "spanId", ref.context.spanID)
+		bytes = append(bytes, '}')
 	}
-	sp.prefix = enc.encodeKeyInt(sp.prefix, "start", sp.start.UnixNano()/1e3)
+	bytes = append(bytes, ']')
+	return bytes
 }
 
 func (enc *spanEncoder) encodeTags(bytes []byte, tags map[string]interface{}) []byte {
@@ -80,14 +105,35 @@ func (enc *spanEncoder) encodeTags(bytes []byte, tags map[string]interface{}) []
 	bytes = enc.encodeKey(bytes, "tags")
 	bytes = append(bytes, '{')
 
-	for k, v := range tags {
-		bytes = enc.encodeKeyValue(bytes, k, v)
+	if keys := invalidTagKeys(tags); keys != nil {
+		names, keys := sortedValidKeys(keys)
+		for i, k := range keys {
+			bytes = enc.encodeKeyValue(bytes, names[i], tags[k])
+		}
+	} else {
+		for k, v := range tags {
+			bytes = enc.encodeKeyValue(bytes, k, v)
+		}
 	}
 
 	bytes = append(bytes, '}')
 	return bytes
 }
 
+// invalidTagKeys returns all keys of tags if any is not valid UTF-8, or else nil.
+func invalidTagKeys(tags map[string]interface{}) []string {
+	for k := range tags {
+		if !utf8.ValidString(k) {
+			keys := make([]string, 0, len(tags))
+			for k := range tags {
+				keys = append(keys, k)
+			}
+			return keys
+		}
+	}
+	return nil
+}
+
 func (enc *spanEncoder) encodeBaggage(
 	bytes []byte,
 	baggage map[string]string,
@@ -99,14 +145,36 @@ func (enc *spanEncoder) encodeBaggage(
 	bytes = enc.encodeKey(bytes, "baggage")
 	bytes = append(bytes, '{')
 
-	for k, v := range baggage {
-		bytes = enc.encodeKeyString(bytes, k, v)
+	if keys := invalidBaggageKeys(baggage); keys != nil {
+		names, keys := sortedValidKeys(keys)
+		for i, k := range keys {
+			bytes = enc.encodeKeyString(bytes, names[i], baggage[k])
+		}
+	} else {
+		for k, v := range baggage {
+			bytes = enc.encodeKeyString(bytes, k, v)
+		}
 	}
 
 	bytes = append(bytes, '}')
 	return bytes
 }
 
+// invalidBaggageKeys returns all keys of baggage if any is not valid UTF-8,
+// or else nil.
+func invalidBaggageKeys(baggage map[string]string) []string {
+	for k := range baggage {
+		if !utf8.ValidString(k) {
+			keys := make([]string, 0, len(baggage))
+			for k := range baggage {
+				keys = append(keys, k)
+			}
+			return keys
+		}
+	}
+	return nil
+}
+
 func (enc *spanEncoder) encodeLogs(bytes []byte, logs []opentracing.LogRecord) []byte {
 	if logs == nil || len(logs) <= 0 {
 		return bytes
@@ -125,10 +193,11 @@ func (enc *spanEncoder) encodeLogs(bytes []byte, logs []opentracing.LogRecord) [
 		}
 		bytes = append(bytes, '{')
 		bytes = enc.encodeKeyInt(bytes, "timestamp", log.Timestamp.UnixNano()/1e3)
+		fe := logFieldEncoder{enc: &enc.jsonEncoder, bytes: bytes}
 		for _, f := range log.Fields {
-			bytes = enc.encodeKeyValue(bytes, f.Key(), f.Value())
+			marshalField(f, &fe)
 		}
-		bytes = append(bytes, '}')
+		bytes = append(fe.bytes, '}')
 	}
 	bytes = append(bytes, ']')
 	return bytes
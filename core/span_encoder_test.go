@@ -1,6 +1,7 @@
 package core
 
 import (
+	"errors"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -22,10 +23,10 @@ var _ = Describe("SpanEncoder", func() {
 
 	Describe("Encode", func() {
 		It("encodes minimal span", func() {
-			sp := &span{
+			sp := &SpanData{
 				operation: "op",
 				context: spanContext{
-					traceID: 123,
+					traceID: TraceID{Low: 123},
 					spanID:  456,
 				},
 				duration: -1,
@@ -36,16 +37,26 @@ var _ = Describe("SpanEncoder", func() {
 		})
 
 		It("encodes full span", func() {
-			sp := &span{
+			sp := &SpanData{
 				operation: "op",
 				context: spanContext{
-					traceID: 123,
+					traceID: TraceID{Low: 123},
 					spanID:  456,
 					baggage: map[string]string{
 						"bkey1": "bval1",
 					},
 				},
 				parentID: 789,
+				references: []spanReference{
+					{
+						refType: opentracing.ChildOfRef,
+						context: spanContext{traceID: TraceID{Low: 123}, spanID: 789},
+					},
+					{
+						refType: opentracing.FollowsFromRef,
+						context: spanContext{traceID: TraceID{Low: 124}, spanID: 790},
+					},
+				},
 				start:    time.Now(),
 				duration: 35000,
 				tags: map[string]interface{}{
@@ -64,11 +75,78 @@ var _ = Describe("SpanEncoder", func() {
 			bytes = enc.Encode(sp)
 			Ω(string(bytes)).Should(MatchRegexp(
 				`\{"traceId":"000000000000007b","spanId":"00000000000001c8","parentId":"0000000000000315",` +
+					`"references":\[\{"type":"child_of","traceId":"000000000000007b","spanId":"0000000000000315"\},` +
+					`\{"type":"follows_from","traceId":"000000000000007c","spanId":"0000000000000316"\}\],` +
 					`"operation":"op","start":\d{16},"duration":35,` +
 					`"tags":\{"key1":"val1"\},` +
 					`"baggage":\{"bkey1":"bval1"\},` +
 					`"logs":\[\{"timestamp":\d{16},"event":"evt1","key1":99\}\]\}`))
 
 		})
+
+		It("renames timestamp fields and drops duplicate fields", func() {
+			sp := &SpanData{
+				operation: "op",
+				duration:  -1,
+				logs: []opentracing.LogRecord{
+					{
+						Timestamp: time.Now(),
+						Fields: []log.Field{
+							log.String("event", "evt1"),
+							log.Int("timestamp", 1),
+							log.String("event", "evt2"),
+						},
+					},
+				},
+			}
+			bytes = enc.Encode(sp)
+			Ω(string(bytes)).Should(MatchRegexp(
+				`"logs":\[\{"timestamp":\d{16},"event":"evt1","_timestamp":1\}\]\}`))
+		})
+
+		It("encodes log fields by kind", func() {
+			sp := &SpanData{
+				operation: "op",
+				duration:  -1,
+				logs: []opentracing.LogRecord{
+					{
+						Timestamp: time.Now(),
+						Fields: []log.Field{
+							log.Uint32("u32", 7),
+							log.Float32("f32", 0.5),
+							log.Error(errors.New("boom")),
+							log.Object("obj", map[string]int{"a": 1}),
+							log.Lazy(func(fe log.Encoder) {
+								fe.EmitString("lazy", "yes")
+								fe.EmitInt64("n", 2)
+							}),
+							log.Object("fn", func() {}),
+						},
+					},
+				},
+			}
+			bytes = enc.Encode(sp)
+			Ω(string(bytes)).Should(MatchRegexp(
+				`"logs":\[\{"timestamp":\d{16},"u32":7,"f32":0.5,"error.object":"boom",` +
+					`"obj":\{"a":1\},"lazy":"yes","n":2,"fn":"<unsupported func\(\)>"\}\]\}`))
+		})
+
+		It("encodes typed nil errors as null", func() {
+			var err *jsonTestError
+			sp := &SpanData{
+				operation: "op",
+				duration:  -1,
+				tags:      map[string]interface{}{"err": err},
+				logs: []opentracing.LogRecord{
+					{Timestamp: time.Now(), Fields: []log.Field{log.Error(err)}},
+				},
+			}
+			for _, e := range []SpanEncoder{enc, NewProtobufSpanEncoder(), NewConsoleEncoder(ConsoleEncoderOptions{})} {
+				Ω(func() { e.Encode(sp) }).ShouldNot(Panic())
+			}
+			bytes = enc.Encode(sp)
+			Ω(string(bytes)).Should(ContainSubstring(`"tags":{"err":null}`))
+			Ω(string(bytes)).Should(ContainSubstring(`"error.object":null`))
+		})
 	})
 })
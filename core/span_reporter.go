@@ -1,23 +1,33 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"sync"
-
-	opentracing "github.com/opentracing/opentracing-go"
 )
 
 // SpanReporter reports the current state of a Span.  It is intended to reports
 // Start-Span, Log, and Finish-Span events.
 type SpanReporter interface {
-	Report(opentracing.Span)
+	// Report reports a snapshot of the Span taken when the event occurred.
+	Report(*SpanData)
+
+	// Flush writes all buffered events and flushes the io.Writer if it has a
+	// Flush() error method, or returns ctx.Err() if ctx is done first.
+	Flush(ctx context.Context) error
+
+	// Close flushes all buffered events and releases the resources of the
+	// SpanReporter.  Events reported after Close are discarded.  It does not
+	// close the io.Writer.
+	Close(ctx context.Context) error
 }
 
 type spanReporter struct {
 	io.Writer
 	SpanEncoder
 	sync.Mutex
+	closed bool
 }
 
 // NewSpanReporter creates a new default SpanReporter.
@@ -25,12 +35,15 @@ func NewSpanReporter(w io.Writer, e SpanEncoder) SpanReporter {
 	return &spanReporter{Writer: w, SpanEncoder: e}
 }
 
-func (r *spanReporter) Report(sp opentracing.Span) {
+func (r *spanReporter) Report(sp *SpanData) {
 	bytes := r.Encode(sp)
 	expectedBytes := len(bytes)
 
 	r.Lock()
 	defer r.Unlock()
+	if r.closed {
+		return
+	}
 	n, err := r.Write(bytes)
 
 	if err != nil {
@@ -42,3 +55,40 @@ func (r *spanReporter) Report(sp opentracing.Span) {
 		fmt.Printf("Expect %d bytes reported, but had %d instead\n", expectedBytes, n)
 	}
 }
+
+func (r *spanReporter) Flush(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		r.Lock()
+		defer r.Unlock()
+		done <- flushWriter(r.Writer)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *spanReporter) Close(ctx context.Context) error {
+	r.Lock()
+	r.closed = true
+	r.Unlock()
+	return r.Flush(ctx)
+}
+
+// flushWriter flushes w if it buffers writes, like a bufio.Writer does.
+func flushWriter(w io.Writer) error {
+	if f, ok := w.(interface {
+		Flush() error
+	}); ok {
+		return f.Flush()
+	}
+	return nil
+}
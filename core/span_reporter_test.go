@@ -1,7 +1,9 @@
 package core
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"strings"
 
 	. "github.com/onsi/ginkgo"
@@ -26,10 +28,10 @@ var _ = Describe("SpanReporter", func() {
 
 	Describe("Report", func() {
 		It("reports one span", func() {
-			sp := &span{
+			sp := &SpanData{
 				operation: "op",
 				context: spanContext{
-					traceID: 123,
+					traceID: TraceID{Low: 123},
 					spanID:  456,
 				},
 				duration: -1,
@@ -40,10 +42,10 @@ var _ = Describe("SpanReporter", func() {
 		})
 
 		It("reports two spans", func() {
-			sp := &span{
+			sp := &SpanData{
 				operation: "op",
 				context: spanContext{
-					traceID: 123,
+					traceID: TraceID{Low: 123},
 					spanID:  456,
 				},
 				duration: -1,
@@ -57,4 +59,29 @@ var _ = Describe("SpanReporter", func() {
 				`\{"traceId":"000000000000007b","spanId":"00000000000001c8","operation":"op","start":\d{16},"duration":35\}`))
 		})
 	})
+
+	Describe("Flush", func() {
+		It("flushes buffered writers", func() {
+			bw := bufio.NewWriter(&buf)
+			rep = NewSpanReporter(bw, NewSpanEncoder())
+			rep.Report(&SpanData{operation: "op", duration: -1})
+			Ω(buf.Len()).Should(BeZero())
+			Ω(rep.Flush(context.Background())).Should(Succeed())
+			Ω(lines(buf)[0]).Should(ContainSubstring(`"operation":"op"`))
+		})
+
+		It("returns when ctx is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			Ω(rep.Flush(ctx)).Should(Equal(context.Canceled))
+		})
+	})
+
+	Describe("Close", func() {
+		It("discards later events", func() {
+			Ω(rep.Close(context.Background())).Should(Succeed())
+			rep.Report(&SpanData{operation: "op", duration: -1})
+			Ω(buf.Len()).Should(BeZero())
+		})
+	})
 })
@@ -91,6 +91,7 @@ var _ = Describe("Span", func() {
 					sp.Finish()
 					Ω(buf.Lines()[0]).Should(MatchRegexp(
 						`\{"traceId":"000000000000007b","spanId":"[0-9a-f]{16}","parentId":"00000000000001c8",` +
+							`"references":\[\{"type":"child_of","traceId":"000000000000007b","spanId":"00000000000001c8"\}\],` +
 							`"operation":"x","start":\d+,"finish":\d+,"duration":\d+,` +
 							`"logs":\[\{"timestamp":\d+,"event":"Start-Span"\},\{"timestamp":\d+,"event":"Finish-Span"\}\]\}`))
 				})
@@ -115,6 +116,41 @@ var _ = Describe("Span", func() {
 		})
 	})
 
+	Context("with Single-Event Mode and debug parent", func() {
+		BeforeEach(func() {
+			buf.Reset()
+			trc = core.NewWithOptions(core.TracerOptions{
+				Writer:  &buf,
+				Sampler: core.NewConstSampler(false),
+			})
+			parent, _ := trc.Extract(opentracing.TextMap, opentracing.TextMapCarrier{
+				"ct-trace-id": "7b",
+				"ct-span-id":  "1c8",
+				"ct-sampled":  "0",
+				"ct-debug":    "1",
+			})
+			sp = trc.StartSpan("x", opentracing.ChildOf(parent))
+		})
+
+		It("outputs every event", func() {
+			sp.LogFields(log.String("key_str", "value"))
+			sp.Finish()
+			Ω(buf.Lines()[0]).Should(MatchRegexp(
+				`\{"traceId":"000000000000007b","spanId":"[0-9a-f]{16}","parentId":"00000000000001c8",` +
+					`"references":\[\{"type":"child_of","traceId":"000000000000007b","spanId":"00000000000001c8"\}\],` +
+					`"operation":"x","start":\d+,"logs":\[\{"timestamp":\d+,"event":"Start-Span"\}\]\}`))
+			Ω(buf.Lines()[1]).Should(MatchRegexp(`"logs":\[\{"timestamp":\d+,"key_str":"value"\}\]\}`))
+			Ω(buf.Lines()[2]).Should(MatchRegexp(`"logs":\[\{"timestamp":\d+,"event":"Finish-Span"\}\]\}`))
+		})
+
+		It("outputs child events", func() {
+			child := trc.StartSpan("y", opentracing.ChildOf(sp.Context()))
+			child.Finish()
+			Ω(buf.Spans()).Should(HaveLen(3))
+			Ω(buf.Spans()[1].Operation).Should(Equal("y"))
+		})
+	})
+
 	Context("with Multi-Event Mode", func() {
 		BeforeEach(func() {
 			buf.Reset()
@@ -151,6 +187,7 @@ var _ = Describe("Span", func() {
 					sp.LogFields(log.String("key_str", "value"))
 					Ω(buf.Lines()[1]).Should(MatchRegexp(
 						`\{"traceId":"000000000000007b","spanId":"[0-9a-f]{16}","parentId":"00000000000001c8",` +
+							`"references":\[\{"type":"child_of","traceId":"000000000000007b","spanId":"00000000000001c8"\}\],` +
 							`"operation":"x","start":\d+,"logs":\[\{"timestamp":\d+,"key_str":"value"\}\]\}`))
 				})
 			})
@@ -232,6 +269,7 @@ var _ = Describe("Span", func() {
 					sp.Finish()
 					Ω(buf.Lines()[1]).Should(MatchRegexp(
 						`\{"traceId":"000000000000007b","spanId":"[0-9a-f]{16}","parentId":"00000000000001c8",` +
+							`"references":\[\{"type":"child_of","traceId":"000000000000007b","spanId":"00000000000001c8"\}\],` +
 							`"operation":"x","start":\d+,"finish":\d+,"duration":\d+,"logs":\[\{"timestamp":\d+,"event":"Finish-Span"\}\]\}`))
 				})
 			})
@@ -1,9 +1,11 @@
 package core
 
 import (
+	"context"
 	"io"
 	"math/rand"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -16,6 +18,19 @@ import (
 type Tracer interface {
 	opentracing.Tracer
 	StartSpanWithOptions(string, opentracing.StartSpanOptions) opentracing.Span
+
+	// InjectSpan injects the SpanContext of sp like Inject, and logs the
+	// baggage withheld by the BaggagePolicy as a "Baggage-Dropped" event on
+	// sp, which Inject cannot do as it does not know the Span.
+	InjectSpan(sp opentracing.Span, format interface{}, carrier interface{}) error
+
+	// Flush writes all buffered trace events, or returns ctx.Err() if ctx is
+	// done first.
+	Flush(ctx context.Context) error
+
+	// Close flushes all buffered trace events and stops the SpanReporter.
+	// Spans finished after Close are not reported.
+	Close(ctx context.Context) error
 }
 
 // Tracer Implements the `Tracer` interface.
@@ -25,8 +40,11 @@ type tracer struct {
 	spanPool *sync.Pool
 	rng      *rand.Rand
 	sync.Mutex
-	textMapPropagator     *textMapPropagator
-	httpHeadersPropagator *textMapPropagator
+	textMapPropagator     Propagator
+	httpHeadersPropagator Propagator
+	binaryPropagator      *binaryPropagator
+	baggagePolicy         *baggagePolicy
+	baggageSigner         *baggageSigner
 }
 
 // TracerOptions allows creating a customized Tracer via NewWithOptions. The object
@@ -43,6 +61,90 @@ type TracerOptions struct {
 	// Writer is used to write serialized trace events.  It defaults to os.Stdout.
 	Writer io.Writer
 
+	// Encoder serializes the trace events written to Writer by the default
+	// Reporter.  It defaults to JSON, or to a console encoder if environment
+	// variable "CTRACE_FORMAT" is "console" or "logfmt", with colors if Writer
+	// is a terminal, or to protobuf if it is "protobuf".  See
+	// NewConsoleEncoder and NewProtobufSpanEncoder.
+	Encoder SpanEncoder
+
+	// Reporter is used to report trace events.  It defaults to a synchronous
+	// SpanReporter writing events serialized by Encoder to Writer.  Use
+	// NewAsyncSpanReporter to keep slow writers off the request path.
+	Reporter SpanReporter
+
+	// Sampler decides which new traces are reported.  Spans of unsampled traces
+	// still propagate their IDs and baggage, but are never passed to the
+	// SpanReporter.  It defaults to sampling every trace.
+	Sampler Sampler
+
+	// TextMapPropagator injects and extracts SpanContexts in the TextMap
+	// format.  It defaults to the ct-* keys.
+	TextMapPropagator Propagator
+
+	// HTTPHeadersPropagator injects and extracts SpanContexts in the
+	// HTTPHeaders format, used by TracedHTTPHandler and
+	// TracedHTTPClientTransport.  It defaults to the ct-* headers.  Use
+	// NewW3CPropagator for W3C Trace Context.
+	HTTPHeadersPropagator Propagator
+
+	// PropagationKeys replaces the ct-* keys of the default TextMap and
+	// HTTPHeaders Propagators, e.g. to match a legacy scheme.  It is ignored
+	// for Propagators set above.
+	PropagationKeys PropagationKeys
+
+	// PropagationKeyAliases are further keys the default Propagators accept
+	// when extracting, but never inject, e.g. the old keys while migrating to
+	// PropagationKeys.
+	PropagationKeyAliases []PropagationKeys
+
+	// BaggagePolicy restricts the baggage accepted from remote callers, set
+	// on Spans, and sent to remote services.  By default all baggage is
+	// accepted and propagated.
+	BaggagePolicy BaggagePolicy
+
+	// BaggageSigningKeys sign the baggage of injected SpanContexts and verify
+	// the baggage of extracted ones using HMAC-SHA256, so values such as a
+	// tenant ID can be trusted between services sharing the keys.  Items
+	// failing verification are dropped and logged.  The first key signs and
+	// all keys verify, so a key is rotated by adding the new key last, then
+	// moving it first, and finally removing the old key once every service
+	// has been updated.  By default baggage is neither signed nor verified.
+	BaggageSigningKeys [][]byte
+
+	// Processors are called in order when a sampled Span starts, logs, and
+	// finishes, before the event is reported.  See SpanProcessor.
+	Processors []SpanProcessor
+
+	// Redactor scrubs sensitive data from tags, logs, and baggage before they
+	// are reported.  By default nothing is redacted.
+	Redactor Redactor
+
+	// MaxTags limits the number of tags of a Span, not counting the tags set
+	// by the tracer like "service".  Further tags are dropped and counted as
+	// "droppedTags", and the tags given to StartSpan are set in sorted order so
+	// the same ones are kept.  0 (default) means no limit.
+	MaxTags int
+
+	// MaxLogs limits the number of logs recorded on a Span, not counting the
+	// Start-Span and Finish-Span events.  Further logs are dropped and counted
+	// as "droppedLogs".  0 (default) means no limit.
+	MaxLogs int
+
+	// MaxValueLength limits the length in bytes of string tag and log field
+	// values, and of the strings nested in them.  Longer values are
+	// truncated.  0 (default) means no limit.
+	MaxValueLength int
+
+	// MaxBaggageBytes limits the total length of the baggage keys and values
+	// of a Span.  Items that do not fit are dropped and counted as
+	// "droppedBaggage".  0 (default) means no limit.
+	MaxBaggageBytes int
+
+	// TraceID128Bit tells the tracer to generate 128-bit trace IDs for new
+	// traces.  By default trace IDs are 64-bit.
+	TraceID128Bit bool
+
 	// ServiceName allows the configuration of the "service" tag for the entire Tracer.
 	// If not specified here, it can also be specified using environment variable "CTRACE_SERVICE"
 	ServiceName string
@@ -102,13 +204,37 @@ func NewWithOptions(opts TracerOptions) Tracer {
 		opts.ServiceName = os.Getenv("CTRACE_SERVICE_NAME")
 	}
 
+	if opts.Sampler == nil {
+		opts.Sampler = NewConstSampler(true)
+	}
+
+	keys := append([]PropagationKeys{opts.PropagationKeys}, opts.PropagationKeyAliases...)
+	if opts.TextMapPropagator == nil {
+		opts.TextMapPropagator = newTextMapPropagator(keys...)
+	}
+
+	if opts.HTTPHeadersPropagator == nil {
+		opts.HTTPHeadersPropagator = newHTTPHeadersPropagator(keys...)
+	}
+
+	if opts.Encoder == nil {
+		opts.Encoder = newEnvEncoder(opts.Writer)
+	}
+
+	if opts.Reporter == nil {
+		opts.Reporter = NewSpanReporter(opts.Writer, opts.Encoder)
+	}
+
 	return &tracer{
 		options:               opts,
-		SpanReporter:          NewSpanReporter(opts.Writer, NewSpanEncoder()),
+		SpanReporter:          opts.Reporter,
 		spanPool:              &sync.Pool{New: func() interface{} { return &span{} }},
 		rng:                   rand.New(rand.NewSource(time.Now().UnixNano())),
-		textMapPropagator:     newTextMapPropagator(),
-		httpHeadersPropagator: newHTTPHeadersPropagator(),
+		textMapPropagator:     opts.TextMapPropagator,
+		httpHeadersPropagator: opts.HTTPHeadersPropagator,
+		binaryPropagator:      &binaryPropagator{},
+		baggagePolicy:         newBaggagePolicy(opts.BaggagePolicy),
+		baggageSigner:         newBaggageSigner(opts.BaggageSigningKeys),
 	}
 }
 
@@ -138,89 +264,205 @@ func (t *tracer) StartSpanWithOptions(
 	sp.tracer = t
 	sp.start = startTime
 	sp.operation = operationName
-	sp.tags = opts.Tags
-
 	if t.options.ServiceName != "" {
-		sp.setTag("service", t.options.ServiceName)
+		sp.setTracerTag("service", t.options.ServiceName)
 	}
 
-	sp.logs = make([]opentracing.LogRecord, 0, 10)
-	sp.logs = append(sp.logs, opentracing.LogRecord{
-		Timestamp: startTime,
-		Fields:    []log.Field{log.String("event", "Start-Span")},
-	})
-
 	if t.options.DebugAssertSingleGoroutine {
-		sp.setTag(debugGoroutineIDTag, curGoroutineID())
+		sp.setTracerTag(debugGoroutineIDTag, curGoroutineID())
 	}
 
-	// Look for a parent in the list of References.
-	//
-	// TODO: would be nice if basictracer did something with all
-	// References, not just the first one.
+	// Set the tags in sorted order, so MaxTags always drops the same ones.
+	// Tags set by the tracer take precedence.
+	keys := make([]string, 0, len(opts.Tags))
+	for k := range opts.Tags {
+		if _, set := sp.tags[k]; !set {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sp.setTag(k, opts.Tags[k])
+	}
+
+	// Keep every reference, and continue the trace of the first ChildOf
+	// reference, or else of the first FollowsFrom reference.  Baggage is
+	// merged from all references, with the parent's items taking precedence.
+	// References created by Link are kept, but never continued.
+	parent := -1
+	var root *spanContext
+	var violations baggageViolations
 	for _, ref := range opts.References {
-		if ref.ReferencedContext == nil {
+		if link, ok := ref.ReferencedContext.(linkContext); ok {
+			sp.references = append(sp.references, spanReference{
+				refType: ref.Type,
+				context: link.spanContext,
+			})
+			continue
+		}
+		refCtx, ok := ref.ReferencedContext.(spanContext)
+		if !ok {
+			continue
+		}
+		violations = violations.add(refCtx.baggageViolations)
+		sp.context.debug = sp.context.debug || refCtx.debug
+		sp.mergeBaggage(refCtx.baggage)
+		if !refCtx.traceID.IsValid() {
+			// A debug request without a trace to continue.
 			continue
 		}
-		refCtx := ref.ReferencedContext.(spanContext)
+		if refCtx.spanID == 0 {
+			// A trace started by the caller without a Span, e.g. an X-Ray
+			// Root without Parent, which the Span continues as its root.
+			if root == nil {
+				root = &refCtx
+			}
+			continue
+		}
+		sp.references = append(sp.references, spanReference{
+			refType: ref.Type,
+			context: refCtx,
+		})
+		if parent < 0 || (ref.Type == opentracing.ChildOfRef &&
+			sp.references[parent].refType != opentracing.ChildOfRef) {
+			parent = len(sp.references) - 1
+		}
+	}
+	deferred := false
+	if parent >= 0 {
+		refCtx := sp.references[parent].context
 		sp.context.traceID = refCtx.traceID
 		sp.context.spanID = t.randomID()
+		sp.context.sampled = refCtx.sampled
+		sp.context.traceState = refCtx.traceState
 		sp.parentID = refCtx.spanID
-
-		if l := len(refCtx.baggage); l > 0 {
-			sp.context.baggage = make(map[string]string, l)
-			for k, v := range refCtx.baggage {
-				sp.context.baggage[k] = v
-			}
+		if len(sp.references) > 1 {
+			sp.mergeBaggage(refCtx.baggage)
 		}
-		break
+		deferred = refCtx.samplingDeferred
+	} else if root != nil {
+		sp.context.traceID = root.traceID
+		sp.context.spanID = t.randomID()
+		sp.context.sampled = root.sampled
+		sp.context.traceState = root.traceState
+		deferred = root.samplingDeferred
 	}
-	if sp.context.traceID == 0 {
+	if !sp.context.traceID.IsValid() {
 		// No parent Span found; allocate new trace and span ids and determine
 		// the Sampled status.
-		sp.context.traceID = t.randomID()
-		sp.context.spanID = sp.context.traceID
+		sp.context.traceID = t.randomTraceID()
+		sp.context.spanID = sp.context.traceID.Low
+		deferred = true
+	}
+	if deferred {
+		sp.context.sampled = sp.context.debug ||
+			t.options.Sampler.IsSampled(sp.context.traceID, operationName)
+	}
+
+	if !sp.context.sampled {
+		return sp
 	}
 
-	if t.options.MultiEvent {
-		t.Report(sp)
+	sp.logs = make([]opentracing.LogRecord, 0, 10)
+	sp.logs = append(sp.logs, opentracing.LogRecord{
+		Timestamp: startTime,
+		Fields:    []log.Field{log.String("event", "Start-Span")},
+	})
+
+	if sp.processStart() && sp.multiEvent() {
+		t.Report(sp.snapshot())
+	}
+	for _, l := range violations.logRecords() {
+		sp.reportLog(l)
 	}
 	return sp
 }
 
 func (t *tracer) Inject(sc opentracing.SpanContext, format interface{}, carrier interface{}) error {
+	_, err := t.inject(sc, format, carrier)
+	return err
+}
+
+func (t *tracer) InjectSpan(sp opentracing.Span, format interface{}, carrier interface{}) error {
+	violations, err := t.inject(sp.Context(), format, carrier)
+	if s, ok := sp.(*span); ok {
+		for _, l := range violations.logRecords() {
+			s.reportLog(l)
+		}
+	}
+	return err
+}
+
+// inject injects sc, returning the baggage withheld by the BaggagePolicy.
+func (t *tracer) inject(
+	sc opentracing.SpanContext,
+	format interface{},
+	carrier interface{},
+) (baggageViolations, error) {
+	var violations baggageViolations
+	if c, ok := sc.(spanContext); ok && (t.baggagePolicy != nil || t.baggageSigner != nil) {
+		if t.baggagePolicy != nil {
+			c.baggage, violations = t.baggagePolicy.filter(c.baggage, baggageOutbound)
+		}
+		if t.baggageSigner != nil {
+			c.baggage = t.baggageSigner.sign(c)
+		}
+		sc = c
+	}
 	switch format {
 	case opentracing.TextMap:
-		return t.textMapPropagator.Inject(sc, carrier)
+		return violations, t.textMapPropagator.Inject(sc, carrier)
 	case opentracing.HTTPHeaders:
-		return t.httpHeadersPropagator.Inject(sc, carrier)
+		return violations, t.httpHeadersPropagator.Inject(sc, carrier)
 	case opentracing.Binary:
-		return opentracing.ErrUnsupportedFormat
+		return violations, t.binaryPropagator.Inject(sc, carrier)
 	}
-	return opentracing.ErrUnsupportedFormat
+	return nil, opentracing.ErrUnsupportedFormat
 }
 
 func (t *tracer) Extract(format interface{}, carrier interface{}) (opentracing.SpanContext, error) {
+	var sc opentracing.SpanContext
+	var err error
 	switch format {
 	case opentracing.TextMap:
-		return t.textMapPropagator.Extract(carrier)
+		sc, err = t.textMapPropagator.Extract(carrier)
 	case opentracing.HTTPHeaders:
-		return t.httpHeadersPropagator.Extract(carrier)
+		sc, err = t.httpHeadersPropagator.Extract(carrier)
 	case opentracing.Binary:
+		sc, err = t.binaryPropagator.Extract(carrier)
+	default:
 		return nil, opentracing.ErrUnsupportedFormat
 	}
-	return nil, opentracing.ErrUnsupportedFormat
+	if c, ok := sc.(spanContext); ok && (t.baggagePolicy != nil || t.baggageSigner != nil) {
+		var violations baggageViolations
+		if t.baggageSigner != nil {
+			c.baggage, c.baggageViolations = t.baggageSigner.verify(c)
+		}
+		if t.baggagePolicy != nil {
+			c.baggage, violations = t.baggagePolicy.filter(c.baggage, baggageInbound)
+			c.baggageViolations = c.baggageViolations.add(violations)
+		}
+		sc = c
+	}
+	return sc, err
 }
 
 // newSpan retrieves an instance of a clean Span object.
 func (t *tracer) newSpan() *span {
 	sp := t.spanPool.Get().(*span)
 	sp.context = spanContext{}
+	sp.parentID = 0
+	sp.references = nil
 	sp.duration = -1
 	sp.tracer = nil
 	sp.tags = nil
 	sp.logs = nil
-	sp.prefix = nil
+	sp.numLogs = 0
+	sp.droppedTags = 0
+	sp.droppedLogs = 0
+	sp.droppedBaggage = 0
+	sp.tracerTags = 0
+	sp.filtered = false
 	return sp
 }
 
@@ -232,6 +474,16 @@ func (t *tracer) randomNumber() uint64 {
 	return uint64(t.rng.Int63())
 }
 
+// randomTraceID generates a random 64 or 128-bit trace ID, depending on the
+// TraceID128Bit option.
+func (t *tracer) randomTraceID() TraceID {
+	id := TraceID{Low: t.randomID()}
+	if t.options.TraceID128Bit {
+		id.High = t.randomID()
+	}
+	return id
+}
+
 // randomID generates a random trace/span ID, using tracer.random() generator.
 // It never returns 0.
 func (t *tracer) randomID() uint64 {
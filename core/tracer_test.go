@@ -1,7 +1,9 @@
 package core_test
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"os"
@@ -11,6 +13,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
 )
 
 var _ = Describe("Tracer", func() {
@@ -51,11 +54,73 @@ var _ = Describe("Tracer", func() {
 				_ = trc.StartSpan("x", opentracing.ChildOf(sc))
 				Ω(buf.String()).Should(MatchRegexp(
 					`\{"traceId":"000000000000007b","spanId":"[0-9a-f]{16}","parentId":"00000000000001c8",` +
+						`"references":\[\{"type":"child_of","traceId":"000000000000007b","spanId":"00000000000001c8"\}\],` +
 						`"operation":"x","start":\d+,"logs":\[\{"timestamp":\d+,"event":"Start-Span"\}\]\}`))
 
 			})
 		})
 
+		Context("with FollowsFrom", func() {
+			It("continues the trace", func() {
+				sc := core.NewSpanContext(123, 456, nil)
+				_ = trc.StartSpan("x", opentracing.FollowsFrom(sc))
+				Ω(buf.String()).Should(MatchRegexp(
+					`\{"traceId":"000000000000007b","spanId":"[0-9a-f]{16}","parentId":"00000000000001c8",` +
+						`"references":\[\{"type":"follows_from","traceId":"000000000000007b","spanId":"00000000000001c8"\}\],`))
+			})
+		})
+
+		Context("with Link", func() {
+			It("starts a new trace referencing the linked one", func() {
+				sc := core.NewSpanContext(123, 456, map[string]string{"k": "v"})
+				sp := trc.StartSpan("x", core.Link(sc))
+				Ω(sp.BaggageItem("k")).Should(BeEmpty())
+				Ω(buf.String()).Should(MatchRegexp(
+					`\{"traceId":"[0-9a-f]{16}","spanId":"[0-9a-f]{16}",` +
+						`"references":\[\{"type":"follows_from","traceId":"000000000000007b","spanId":"00000000000001c8"\}\],`))
+				Ω(sp.Context().(core.SpanContext).TraceID()).ShouldNot(Equal("000000000000007b"))
+			})
+		})
+
+		Context("with multiple References", func() {
+			It("outputs all references and prefers ChildOf as parent", func() {
+				p1 := core.NewSpanContext(1, 11, map[string]string{"k1": "p1", "k2": "p1"})
+				p2 := core.NewSpanContext(2, 22, map[string]string{"k2": "p2"})
+				p3 := core.NewSpanContext(3, 33, nil)
+				_ = trc.StartSpan("x",
+					opentracing.FollowsFrom(p1),
+					opentracing.ChildOf(p2),
+					opentracing.ChildOf(p3),
+				)
+				var sp core.SpanModel
+				Ω(json.Unmarshal(buf.Bytes(), &sp)).Should(Succeed())
+				Ω(sp.TraceID).Should(Equal("0000000000000002"))
+				Ω(sp.ParentID).Should(Equal("0000000000000016"))
+				Ω(sp.References).Should(Equal([]core.ReferenceModel{
+					{Type: "follows_from", TraceID: "0000000000000001", SpanID: "000000000000000b"},
+					{Type: "child_of", TraceID: "0000000000000002", SpanID: "0000000000000016"},
+					{Type: "child_of", TraceID: "0000000000000003", SpanID: "0000000000000021"},
+				}))
+				Ω(sp.Baggage).Should(Equal(map[string]string{"k1": "p1", "k2": "p2"}))
+			})
+		})
+
+		Context("with TraceID128Bit option", func() {
+			It("outputs 128-bit trace id", func() {
+				trc = core.NewWithOptions(core.TracerOptions{Writer: &buf, MultiEvent: true, TraceID128Bit: true})
+				sp := trc.StartSpan("x")
+				Ω(buf.String()).Should(MatchRegexp(`\{"traceId":"[0-9a-f]{32}","spanId":"[0-9a-f]{16}",`))
+				Ω(sp.Context().(core.SpanContext).RawTraceID().Is128Bit()).Should(BeTrue())
+			})
+
+			It("continues 128-bit trace", func() {
+				sc := core.NewSpanContextFromTraceID(core.TraceID{High: 1, Low: 123}, 456, nil)
+				_ = trc.StartSpan("x", opentracing.ChildOf(sc))
+				Ω(buf.String()).Should(MatchRegexp(
+					`\{"traceId":"0000000000000001000000000000007b","spanId":"[0-9a-f]{16}","parentId":"00000000000001c8",`))
+			})
+		})
+
 		Context("with ChildOf and Baggage", func() {
 			It("outputs Start-Span Baggage", func() {
 				sc := core.NewSpanContext(123, 456,
@@ -106,6 +171,66 @@ var _ = Describe("Tracer", func() {
 		})
 	})
 
+	Describe("Sampler", func() {
+		Context("when trace is not sampled", func() {
+			JustBeforeEach(func() {
+				buf.Reset()
+				trc = core.NewWithOptions(core.TracerOptions{
+					Writer:     &buf,
+					MultiEvent: true,
+					Sampler:    core.NewConstSampler(false),
+				})
+			})
+
+			It("does not output any events", func() {
+				sp := trc.StartSpan("x")
+				sp.LogFields(log.String("key", "val"))
+				sp.Finish()
+				Ω(buf.String()).Should(HaveLen(0))
+			})
+
+			It("still propagates IDs", func() {
+				sp := trc.StartSpan("x")
+				txt := map[string]string{}
+				trc.Inject(sp.Context(), opentracing.TextMap, opentracing.TextMapCarrier(txt))
+				Ω(txt["ct-trace-id"]).ShouldNot(BeEmpty())
+				Ω(txt["ct-span-id"]).ShouldNot(BeEmpty())
+				Ω(sp.Context().(core.SpanContext).IsSampled()).Should(BeFalse())
+			})
+
+			It("does not output child spans", func() {
+				sp := trc.StartSpan("x")
+				child := trc.StartSpan("y", opentracing.ChildOf(sp.Context()))
+				child.Finish()
+				sp.Finish()
+				Ω(child.Context().(core.SpanContext).IsSampled()).Should(BeFalse())
+				Ω(buf.String()).Should(HaveLen(0))
+			})
+		})
+
+		Context("with remote parent", func() {
+			JustBeforeEach(func() {
+				buf.Reset()
+				trc = core.NewWithOptions(core.TracerOptions{
+					Writer:     &buf,
+					MultiEvent: true,
+					Sampler:    core.NewConstSampler(false),
+				})
+			})
+
+			It("inherits the remote parent's decision", func() {
+				c, err := trc.Extract(opentracing.TextMap, opentracing.TextMapCarrier{
+					"ct-trace-id": "7b",
+					"ct-span-id":  "f5",
+				})
+				Ω(err).ShouldNot(HaveOccurred())
+				sp := trc.StartSpan("x", opentracing.ChildOf(c))
+				Ω(sp.Context().(core.SpanContext).IsSampled()).Should(BeTrue())
+				Ω(buf.String()).ShouldNot(HaveLen(0))
+			})
+		})
+	})
+
 	Context("with ServiceName option", func() {
 		JustBeforeEach(func() {
 			buf.Reset()
@@ -156,15 +281,42 @@ var _ = Describe("Tracer", func() {
 			It("injects HTTP Headers", func() {
 				hdrs := http.Header{}
 				tracer.Inject(ctx, opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(hdrs))
-				Ω(hdrs.Get("Ct-Span-Id")).Should(Equal("f5"))
-				Ω(hdrs.Get("Ct-Trace-Id")).Should(Equal("7b"))
+				Ω(hdrs.Get("Ct-Span-Id")).Should(Equal("00000000000000f5"))
+				Ω(hdrs.Get("Ct-Trace-Id")).Should(Equal("000000000000007b"))
 			})
 
 			It("injects Text Map", func() {
 				txt := map[string]string{}
 				tracer.Inject(ctx, opentracing.TextMap, opentracing.TextMapCarrier(txt))
-				Ω(txt["ct-span-id"]).Should(Equal("f5"))
-				Ω(txt["ct-trace-id"]).Should(Equal("7b"))
+				Ω(txt["ct-span-id"]).Should(Equal("00000000000000f5"))
+				Ω(txt["ct-trace-id"]).Should(Equal("000000000000007b"))
+			})
+		})
+
+		Context("with sampling flags", func() {
+			It("injects sampled HTTP Header", func() {
+				hdrs := http.Header{}
+				tracer.Inject(ctx, opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(hdrs))
+				Ω(hdrs.Get("Ct-Sampled")).Should(Equal("1"))
+				Ω(hdrs).ShouldNot(HaveKey("Ct-Debug"))
+			})
+
+			It("injects unsampled Text Map", func() {
+				sp := core.NewWithOptions(core.TracerOptions{Sampler: core.NewConstSampler(false)}).StartSpan("x")
+				txt := map[string]string{}
+				tracer.Inject(sp.Context(), opentracing.TextMap, opentracing.TextMapCarrier(txt))
+				Ω(txt["ct-sampled"]).Should(Equal("0"))
+			})
+
+			It("injects debug HTTP Header", func() {
+				dctx, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(http.Header{
+					"Ct-Debug": []string{"1"},
+				}))
+				sp := tracer.StartSpan("x", opentracing.ChildOf(dctx))
+				hdrs := http.Header{}
+				tracer.Inject(sp.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(hdrs))
+				Ω(hdrs.Get("Ct-Sampled")).Should(Equal("1"))
+				Ω(hdrs.Get("Ct-Debug")).Should(Equal("1"))
 			})
 		})
 
@@ -207,6 +359,88 @@ var _ = Describe("Tracer", func() {
 				Ω(ctx.TraceID()).Should(Equal("000000000000007b"))
 				Ω(ctx.SpanID()).Should(Equal("00000000000000f5"))
 			})
+
+			It("extracts core carriers", func() {
+				c, err := tracer.Extract(core.HTTPHeaders, core.HTTPHeadersCarrier(hdrs))
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(c.(core.SpanContext).SpanID()).Should(Equal("00000000000000f5"))
+
+				c, err = tracer.Extract(core.TextMap, core.TextMapCarrier{"ct-trace-id": "7b", "ct-span-id": "f5"})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(c.(core.SpanContext).TraceID()).Should(Equal("000000000000007b"))
+			})
+		})
+
+		Context("with 128-bit trace id", func() {
+			It("extracts Text Map", func() {
+				c, err := tracer.Extract(opentracing.TextMap, opentracing.TextMapCarrier{
+					"ct-trace-id": "4bf92f3577b34da6a3ce929d0e0e4736",
+					"ct-span-id":  "00f067aa0ba902b7",
+				})
+				Ω(err).ShouldNot(HaveOccurred())
+				ctx := c.(core.SpanContext)
+				Ω(ctx.TraceID()).Should(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+				Ω(ctx.RawTraceID()).Should(Equal(core.TraceID{High: 0x4bf92f3577b34da6, Low: 0xa3ce929d0e0e4736}))
+				Ω(ctx.SpanID()).Should(Equal("00f067aa0ba902b7"))
+			})
+
+			It("injects Text Map", func() {
+				ctx := core.NewSpanContextFromTraceID(core.TraceID{High: 1, Low: 123}, 245, nil)
+				txt := map[string]string{}
+				tracer.Inject(ctx, opentracing.TextMap, opentracing.TextMapCarrier(txt))
+				Ω(txt["ct-trace-id"]).Should(Equal("0000000000000001000000000000007b"))
+			})
+
+			It("rejects oversized ids", func() {
+				_, err := tracer.Extract(opentracing.TextMap, opentracing.TextMapCarrier{
+					"ct-trace-id": "4bf92f3577b34da6a3ce929d0e0e47360",
+					"ct-span-id":  "00f067aa0ba902b7",
+				})
+				Ω(err).Should(Equal(opentracing.ErrSpanContextCorrupted))
+			})
+		})
+
+		Context("with sampling flags", func() {
+			It("extracts sampled by default", func() {
+				c, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(hdrs))
+				ctx := c.(core.SpanContext)
+				Ω(ctx.IsSampled()).Should(BeTrue())
+				Ω(ctx.IsDebug()).Should(BeFalse())
+			})
+
+			It("extracts unsampled", func() {
+				hdrs["Ct-Sampled"] = []string{"0"}
+				c, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(hdrs))
+				Ω(c.(core.SpanContext).IsSampled()).Should(BeFalse())
+			})
+
+			It("rejects corrupted sampled flag", func() {
+				hdrs["Ct-Sampled"] = []string{"maybe"}
+				_, err := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(hdrs))
+				Ω(err).Should(Equal(opentracing.ErrSpanContextCorrupted))
+			})
+
+			It("force-samples debug", func() {
+				hdrs["Ct-Sampled"] = []string{"0"}
+				hdrs["Ct-Debug"] = []string{"ticket-42"}
+				c, _ := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(hdrs))
+				ctx := c.(core.SpanContext)
+				Ω(ctx.IsSampled()).Should(BeTrue())
+				Ω(ctx.IsDebug()).Should(BeTrue())
+			})
+
+			It("extracts debug without trace", func() {
+				c, err := tracer.Extract(opentracing.TextMap, opentracing.TextMapCarrier{"ct-debug": "1"})
+				Ω(err).ShouldNot(HaveOccurred())
+				ctx := c.(core.SpanContext)
+				Ω(ctx.IsDebug()).Should(BeTrue())
+
+				sp := tracer.StartSpan("x", opentracing.ChildOf(c))
+				sc := sp.Context().(core.SpanContext)
+				Ω(sc.TraceID()).ShouldNot(Equal("0000000000000000"))
+				Ω(sc.IsSampled()).Should(BeTrue())
+				Ω(sc.IsDebug()).Should(BeTrue())
+			})
 		})
 
 		Context("with baggage", func() {
@@ -222,4 +456,117 @@ var _ = Describe("Tracer", func() {
 			})
 		})
 	})
+
+	Describe("PropagationKeys", func() {
+		var tracer opentracing.Tracer
+
+		BeforeEach(func() {
+			tracer = core.NewWithOptions(core.TracerOptions{
+				PropagationKeys: core.PropagationKeys{
+					TraceID:       "X-Request-Trace",
+					SpanID:        "X-Request-Span",
+					BaggagePrefix: "X-Request-Bag-",
+				},
+				PropagationKeyAliases: []core.PropagationKeys{
+					{TraceID: "ct-trace-id", SpanID: "ct-span-id", BaggagePrefix: "ct-bag-"},
+				},
+			})
+		})
+
+		It("injects the configured keys", func() {
+			ctx := core.NewSpanContext(123, 245, map[string]string{"k": "v"})
+			txt := opentracing.TextMapCarrier{}
+			tracer.Inject(ctx, opentracing.TextMap, txt)
+			Ω(txt).Should(Equal(opentracing.TextMapCarrier{
+				"X-Request-Trace": "000000000000007b",
+				"X-Request-Span":  "00000000000000f5",
+				"ct-sampled":      "1",
+				"X-Request-Bag-k": "v",
+			}))
+		})
+
+		It("extracts the configured keys case-insensitively from HTTP Headers", func() {
+			c, err := tracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(http.Header{
+				"X-Request-Trace":   []string{"7b"},
+				"X-Request-Span":    []string{"f5"},
+				"X-Request-Bag-Foo": []string{"bar"},
+			}))
+			Ω(err).ShouldNot(HaveOccurred())
+			ctx := c.(core.SpanContext)
+			Ω(ctx.TraceID()).Should(Equal("000000000000007b"))
+			Ω(ctx.SpanID()).Should(Equal("00000000000000f5"))
+			Ω(ctx.BaggageItem("foo")).Should(Equal("bar"))
+		})
+
+		It("extracts aliases", func() {
+			c, err := tracer.Extract(opentracing.TextMap, opentracing.TextMapCarrier{
+				"ct-trace-id":  "7b",
+				"ct-span-id":   "f5",
+				"ct-bag-item1": "val1",
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+			ctx := c.(core.SpanContext)
+			Ω(ctx.TraceID()).Should(Equal("000000000000007b"))
+			Ω(ctx.SpanID()).Should(Equal("00000000000000f5"))
+			Ω(ctx.BaggageItem("item1")).Should(Equal("val1"))
+		})
+	})
+
+	Describe("Encoder", func() {
+		BeforeEach(func() {
+			buf.Reset()
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("CTRACE_FORMAT")
+		})
+
+		It("encodes events with the Encoder", func() {
+			trc = core.NewWithOptions(core.TracerOptions{
+				Writer:      &buf,
+				ServiceName: "svc",
+				Encoder:     core.NewConsoleEncoder(core.ConsoleEncoderOptions{}),
+			})
+			trc.StartSpan("op1").Finish()
+			Ω(buf.String()).Should(MatchRegexp(
+				`^\d\d:\d\d:\d\d\.\d{3} [0-9a-f]{8} [0-9a-f]{8} Start-Span  op1\n` +
+					`\d\d:\d\d:\d\d\.\d{3} [0-9a-f]{8} [0-9a-f]{8} Finish-Span op1 duration=\S+ service=svc\n$`))
+		})
+
+		It("selects the console encoder with CTRACE_FORMAT", func() {
+			os.Setenv("CTRACE_FORMAT", "console")
+			trc = core.NewWithOptions(core.TracerOptions{
+				Writer:      &buf,
+				ServiceName: "svc",
+				MultiEvent:  true,
+			})
+			trc.StartSpan("op1", opentracing.Tag{Key: "k", Value: "v"})
+			Ω(buf.String()).Should(MatchRegexp(
+				`^\d\d:\d\d:\d\d\.\d{3} [0-9a-f]{8} [0-9a-f]{8} Start-Span  op1 k=v service=svc\n$`))
+		})
+
+		It("defaults to JSON", func() {
+			os.Setenv("CTRACE_FORMAT", "json")
+			trc = core.NewWithOptions(core.TracerOptions{Writer: &buf})
+			trc.StartSpan("op1").Finish()
+			Ω(json.Unmarshal(buf.Bytes(), &out)).Should(Succeed())
+		})
+	})
+
+	Describe("Close", func() {
+		It("flushes and stops reporting", func() {
+			buf.Reset()
+			bw := bufio.NewWriter(&buf)
+			tracer := core.NewWithOptions(core.TracerOptions{Writer: bw})
+			tracer.StartSpan("op1").Finish()
+			Ω(buf.Len()).Should(BeZero())
+
+			Ω(tracer.Close(context.Background())).Should(Succeed())
+			Ω(buf.String()).Should(ContainSubstring(`"operation":"op1"`))
+
+			tracer.StartSpan("op2").Finish()
+			Ω(tracer.Flush(context.Background())).Should(Succeed())
+			Ω(buf.String()).ShouldNot(ContainSubstring(`"operation":"op2"`))
+		})
+	})
 })
@@ -20,16 +20,27 @@ type Buffer struct {
 
 // SpanModel is used for testing
 type SpanModel struct {
-	TraceID   string                     `json:"traceId"`
-	SpanID    string                     `json:"spanId"`
-	ParentID  string                     `json:"parentId"`
-	Operation string                     `json:"operation"`
-	Start     int64                      `json:"start"`
-	Finish    int64                      `json:"finish"`
-	Duration  int64                      `json:"duration"`
-	Tags      map[string]interface{}     `json:"tags"`
-	Logs      [](map[string]interface{}) `json:"logs"`
-	Baggage   map[string]string          `json:"baggage"`
+	TraceID        string                     `json:"traceId"`
+	SpanID         string                     `json:"spanId"`
+	ParentID       string                     `json:"parentId"`
+	References     []ReferenceModel           `json:"references"`
+	Operation      string                     `json:"operation"`
+	Start          int64                      `json:"start"`
+	Finish         int64                      `json:"finish"`
+	Duration       int64                      `json:"duration"`
+	DroppedTags    int                        `json:"droppedTags"`
+	DroppedLogs    int                        `json:"droppedLogs"`
+	DroppedBaggage int                        `json:"droppedBaggage"`
+	Tags           map[string]interface{}     `json:"tags"`
+	Logs           [](map[string]interface{}) `json:"logs"`
+	Baggage        map[string]string          `json:"baggage"`
+}
+
+// ReferenceModel is used for testing
+type ReferenceModel struct {
+	Type    string `json:"type"`
+	TraceID string `json:"traceId"`
+	SpanID  string `json:"spanId"`
 }
 
 // Spans is used for testing
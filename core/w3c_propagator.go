@@ -0,0 +1,219 @@
+package core
+
+import (
+	"net/url"
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+const (
+	w3cTraceParentKey = "traceparent"
+	w3cTraceStateKey  = "tracestate"
+	w3cBaggageKey     = "baggage"
+
+	w3cVersion       = "00"
+	w3cSampledFlag   = 0x01
+	w3cTraceStateMax = 32
+)
+
+type w3cPropagator struct{}
+
+// NewW3CPropagator creates a Propagator for the W3C Trace Context
+// traceparent and tracestate headers, and the W3C baggage header.  Foreign
+// tracestate entries are passed on unchanged to child Spans.  The debug flag
+// has no W3C equivalent and is not propagated.
+func NewW3CPropagator() Propagator {
+	return &w3cPropagator{}
+}
+
+func (p *w3cPropagator) Inject(
+	ctx opentracing.SpanContext,
+	opaqueCarrier interface{},
+) error {
+	sc, ok := ctx.(spanContext)
+	if !ok {
+		return opentracing.ErrInvalidSpanContext
+	}
+	carrier, ok := opaqueCarrier.(TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+
+	// A debug-only context has no trace to continue.
+	if sc.traceID.IsValid() {
+		b := make([]byte, 0, 55)
+		b = append(b, w3cVersion...)
+		b = append(b, '-')
+		b = appendHexID(b, sc.traceID.High)
+		b = appendHexID(b, sc.traceID.Low)
+		b = append(b, '-')
+		b = appendHexID(b, sc.spanID)
+		if sc.sampled {
+			b = append(b, "-01"...)
+		} else {
+			b = append(b, "-00"...)
+		}
+		carrier.Set(w3cTraceParentKey, string(b))
+
+		if sc.traceState != "" {
+			carrier.Set(w3cTraceStateKey, sc.traceState)
+		}
+	}
+
+	if len(sc.baggage) > 0 {
+		items := make([]string, 0, len(sc.baggage))
+		for k, v := range sc.baggage {
+			items = append(items, w3cEscape(k)+"="+w3cEscape(v))
+		}
+		carrier.Set(w3cBaggageKey, strings.Join(items, ","))
+	}
+	return nil
+}
+
+func (p *w3cPropagator) Extract(
+	opaqueCarrier interface{},
+) (opentracing.SpanContext, error) {
+	carrier, ok := opaqueCarrier.(opentracing.TextMapReader)
+	if !ok {
+		return nil, opentracing.ErrInvalidCarrier
+	}
+
+	var traceParent string
+	var traceState, baggage []string
+	err := carrier.ForeachKey(func(k, v string) error {
+		switch strings.ToLower(k) {
+		case w3cTraceParentKey:
+			traceParent = v
+		case w3cTraceStateKey:
+			traceState = append(traceState, v)
+		case w3cBaggageKey:
+			baggage = append(baggage, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if traceParent == "" {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+
+	sc, ok := parseTraceParent(strings.TrimSpace(traceParent))
+	if !ok {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	sc.traceState = joinTraceState(traceState)
+	sc.baggage = parseW3CBaggage(baggage)
+	return sc, nil
+}
+
+// parseTraceParent parses a traceparent header value of the form
+// version-traceid-parentid-flags.  Versions newer than 00 may append fields,
+// which are ignored.
+func parseTraceParent(s string) (spanContext, bool) {
+	var sc spanContext
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return sc, false
+	}
+	version := s[:2]
+	if !isLowerHex(version) || version == "ff" ||
+		(version == w3cVersion && len(s) != 55) ||
+		(len(s) > 55 && s[55] != '-') {
+		return sc, false
+	}
+
+	traceID, flags := s[3:35], s[53:55]
+	spanID := s[36:52]
+	if !isLowerHex(traceID) || !isLowerHex(spanID) || !isLowerHex(flags) {
+		return sc, false
+	}
+	var err error
+	if sc.traceID, err = ParseTraceID(traceID); err != nil || !sc.traceID.IsValid() {
+		return sc, false
+	}
+	if sc.spanID, err = parseSpanID(spanID); err != nil || sc.spanID == 0 {
+		return sc, false
+	}
+	f, _ := parseSpanID(flags)
+	sc.sampled = f&w3cSampledFlag != 0
+	return sc, true
+}
+
+// joinTraceState combines the tracestate header values into a single list,
+// dropping empty members and members beyond the limit of 32.
+func joinTraceState(values []string) string {
+	var members []string
+	for _, v := range values {
+		for _, m := range strings.Split(v, ",") {
+			if m = strings.TrimSpace(m); m != "" && len(members) < w3cTraceStateMax {
+				members = append(members, m)
+			}
+		}
+	}
+	return strings.Join(members, ",")
+}
+
+// parseW3CBaggage parses baggage header values of the form k1=v1,k2=v2;prop.
+// Properties are ignored.
+func parseW3CBaggage(values []string) map[string]string {
+	var baggage map[string]string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			item = strings.TrimSpace(strings.SplitN(item, ";", 2)[0])
+			kv := strings.SplitN(item, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			k, err := url.PathUnescape(strings.TrimSpace(kv[0]))
+			if err != nil || k == "" {
+				continue
+			}
+			val, err := url.PathUnescape(strings.TrimSpace(kv[1]))
+			if err != nil {
+				continue
+			}
+			if baggage == nil {
+				baggage = make(map[string]string)
+			}
+			baggage[k] = val
+		}
+	}
+	return baggage
+}
+
+// w3cEscape percent-encodes every byte of s other than letters, digits, and
+// "-._~".  Unlike url.QueryEscape, it does not encode spaces as "+", which the
+// baggage header reads as a literal "+".
+func w3cEscape(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '.' || c == '_' || c == '~' {
+			if b != nil {
+				b = append(b, c)
+			}
+			continue
+		}
+		if b == nil {
+			b = append(make([]byte, 0, len(s)+8), s[:i]...)
+		}
+		b = append(b, '%', hex[c>>4], hex[c&15])
+	}
+	if b == nil {
+		return s
+	}
+	return string(b)
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
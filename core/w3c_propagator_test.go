@@ -0,0 +1,140 @@
+package core_test
+
+import (
+	"net/http"
+
+	"github.com/Nordstrom/ctrace-go/core"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+var _ = Describe("W3CPropagator", func() {
+	var (
+		trc core.Tracer
+		buf core.Buffer
+	)
+
+	const traceParent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+	BeforeEach(func() {
+		buf.Reset()
+		trc = core.NewWithOptions(core.TracerOptions{
+			Writer:                &buf,
+			TextMapPropagator:     core.NewW3CPropagator(),
+			HTTPHeadersPropagator: core.NewW3CPropagator(),
+		})
+	})
+
+	extract := func(hdrs http.Header) (core.SpanContext, error) {
+		c, err := trc.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(hdrs))
+		if err != nil {
+			return nil, err
+		}
+		return c.(core.SpanContext), nil
+	}
+
+	It("extracts traceparent", func() {
+		hdrs := http.Header{}
+		hdrs.Set("traceparent", traceParent)
+		c, err := extract(hdrs)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(c.TraceID()).Should(Equal("0af7651916cd43dd8448eb211c80319c"))
+		Ω(c.SpanID()).Should(Equal("b7ad6b7169203331"))
+		Ω(c.IsSampled()).Should(BeTrue())
+	})
+
+	It("round-trips the sampled flag", func() {
+		hdrs := http.Header{}
+		hdrs.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00")
+		c, _ := extract(hdrs)
+		Ω(c.IsSampled()).Should(BeFalse())
+
+		out := http.Header{}
+		Ω(trc.Inject(c, opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(out))).Should(Succeed())
+		Ω(out.Get("traceparent")).Should(Equal("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00"))
+	})
+
+	It("passes foreign tracestate on to child spans", func() {
+		hdrs := http.Header{}
+		hdrs.Set("traceparent", traceParent)
+		hdrs.Add("tracestate", "congo=t61rcWkgMzE")
+		hdrs.Add("tracestate", "rojo=00f067aa0ba902b7, ")
+		c, _ := extract(hdrs)
+
+		sp := trc.StartSpan("op", opentracing.ChildOf(c))
+		out := http.Header{}
+		Ω(trc.Inject(sp.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(out))).Should(Succeed())
+		sp.Finish()
+
+		Ω(out.Get("traceparent")).Should(MatchRegexp(`^00-0af7651916cd43dd8448eb211c80319c-[0-9a-f]{16}-01$`))
+		Ω(out.Get("traceparent")).ShouldNot(ContainSubstring("b7ad6b7169203331"))
+		Ω(out.Get("tracestate")).Should(Equal("congo=t61rcWkgMzE,rojo=00f067aa0ba902b7"))
+	})
+
+	It("pads 64-bit trace IDs", func() {
+		sp := trc.StartSpan("op")
+		out := opentracing.TextMapCarrier{}
+		Ω(trc.Inject(sp.Context(), opentracing.TextMap, out)).Should(Succeed())
+		sp.Finish()
+		Ω(out["traceparent"]).Should(MatchRegexp(`^00-0{16}[0-9a-f]{16}-[0-9a-f]{16}-01$`))
+	})
+
+	It("propagates baggage", func() {
+		c := core.NewSpanContext(1, 2, map[string]string{"user id": "a,b"})
+		out := opentracing.TextMapCarrier{}
+		Ω(trc.Inject(c, opentracing.TextMap, out)).Should(Succeed())
+		Ω(out["baggage"]).Should(Equal("user%20id=a%2Cb"))
+
+		in, err := trc.Extract(opentracing.TextMap, out)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(in.(core.SpanContext).BaggageItem("user id")).Should(Equal("a,b"))
+	})
+
+	It("round-trips plus signs and spaces in baggage", func() {
+		c := core.NewSpanContext(1, 2, map[string]string{"q": "a+b c%;="})
+		out := opentracing.TextMapCarrier{}
+		Ω(trc.Inject(c, opentracing.TextMap, out)).Should(Succeed())
+		Ω(out["baggage"]).Should(Equal("q=a%2Bb%20c%25%3B%3D"))
+
+		in, err := trc.Extract(opentracing.TextMap, out)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(in.(core.SpanContext).BaggageItem("q")).Should(Equal("a+b c%;="))
+
+		in, err = trc.Extract(opentracing.TextMap, opentracing.TextMapCarrier{
+			"traceparent": out["traceparent"],
+			"baggage":     "q=a+b",
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(in.(core.SpanContext).BaggageItem("q")).Should(Equal("a+b"))
+	})
+
+	It("returns not found without traceparent", func() {
+		_, err := extract(http.Header{})
+		Ω(err).Should(Equal(opentracing.ErrSpanContextNotFound))
+	})
+
+	It("rejects invalid traceparent", func() {
+		for _, tp := range []string{
+			"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331",
+			"00-00000000000000000000000000000000-b7ad6b7169203331-01",
+			"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01",
+			"00-0AF7651916CD43DD8448EB211C80319C-b7ad6b7169203331-01",
+			"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+			"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-extra",
+		} {
+			hdrs := http.Header{}
+			hdrs.Set("traceparent", tp)
+			_, err := extract(hdrs)
+			Ω(err).Should(Equal(opentracing.ErrSpanContextCorrupted), tp)
+		}
+	})
+
+	It("accepts future versions", func() {
+		hdrs := http.Header{}
+		hdrs.Set("traceparent", "01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-extra")
+		_, err := extract(hdrs)
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+})
@@ -0,0 +1,152 @@
+package core
+
+import (
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// XRayTraceHeader is the AWS X-Ray trace header, e.g.
+//
+//	X-Amzn-Trace-Id: Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1
+const XRayTraceHeader = "X-Amzn-Trace-Id"
+
+type xrayPropagator struct{}
+
+// NewXRayPropagator creates a Propagator for the AWS X-Ray trace header.  The
+// Root trace ID maps to a 128-bit TraceID made of its epoch and unique parts,
+// and Parent maps to the span ID.  Trace IDs generated by ctrace have no
+// meaningful epoch, so use it to continue traces started by AWS services
+// rather than to start traces sent to X-Ray.  Baggage is not propagated.
+//
+// A missing or "?" Sampled field leaves the decision to the Sampler, and a
+// header without Parent, as sent by load balancers starting a trace, makes
+// the Spans started from it roots of the trace.
+func NewXRayPropagator() Propagator {
+	return &xrayPropagator{}
+}
+
+func (p *xrayPropagator) Inject(
+	ctx opentracing.SpanContext,
+	opaqueCarrier interface{},
+) error {
+	sc, ok := ctx.(spanContext)
+	if !ok {
+		return opentracing.ErrInvalidSpanContext
+	}
+	carrier, ok := opaqueCarrier.(TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+	if !sc.traceID.IsValid() {
+		return nil
+	}
+
+	id := appendHexID(make([]byte, 0, 32), sc.traceID.High)
+	id = appendHexID(id, sc.traceID.Low)
+	b := make([]byte, 0, 80)
+	b = append(b, "Root=1-"...)
+	b = append(b, id[:8]...)
+	b = append(b, '-')
+	b = append(b, id[8:]...)
+	b = append(b, ";Parent="...)
+	b = appendHexID(b, sc.spanID)
+	if sc.samplingDeferred {
+		b = append(b, ";Sampled=?"...)
+	} else if sc.sampled {
+		b = append(b, ";Sampled=1"...)
+	} else {
+		b = append(b, ";Sampled=0"...)
+	}
+	carrier.Set(XRayTraceHeader, string(b))
+	return nil
+}
+
+func (p *xrayPropagator) Extract(
+	opaqueCarrier interface{},
+) (opentracing.SpanContext, error) {
+	carrier, ok := opaqueCarrier.(opentracing.TextMapReader)
+	if !ok {
+		return nil, opentracing.ErrInvalidCarrier
+	}
+
+	var header string
+	err := carrier.ForeachKey(func(k, v string) error {
+		if strings.EqualFold(k, XRayTraceHeader) {
+			header = v
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if header == "" {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+
+	sc := spanContext{samplingDeferred: true}
+	var root, parent string
+	for _, field := range strings.Split(header, ";") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "Root":
+			root = kv[1]
+		case "Parent":
+			parent = kv[1]
+		case "Sampled":
+			switch kv[1] {
+			case "1":
+				sc.sampled, sc.samplingDeferred = true, false
+			case "0":
+				sc.sampled, sc.samplingDeferred = false, false
+			default:
+				// "?" asks the receiver to decide.
+				sc.sampled, sc.samplingDeferred = false, true
+			}
+		}
+	}
+
+	if sc.traceID, ok = parseXRayRoot(root); !ok {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	if parent == "" {
+		// The trace was started without a segment, so there is no parent
+		// Span; a span ID of 0 makes the tracer start a root Span.
+		return sc, nil
+	}
+	if sc.spanID, err = parseSpanID(parent); err != nil || sc.spanID == 0 {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	return sc, nil
+}
+
+// XRayRoot returns the Root trace ID of an X-Ray trace header, or "" if it
+// has none.
+func XRayRoot(header string) string {
+	for _, field := range strings.Split(header, ";") {
+		field = strings.TrimSpace(field)
+		if strings.HasPrefix(field, "Root=") {
+			return field[len("Root="):]
+		}
+	}
+	return ""
+}
+
+// parseXRayRoot parses a Root trace ID of the form 1-{8 hex epoch}-{24 hex}.
+func parseXRayRoot(root string) (TraceID, bool) {
+	if len(root) != 35 || root[:2] != "1-" || root[10] != '-' {
+		return TraceID{}, false
+	}
+	id := root[2:10] + root[11:]
+	if !isLowerHex(id) {
+		return TraceID{}, false
+	}
+	t, err := ParseTraceID(id)
+	if err != nil || !t.IsValid() {
+		return TraceID{}, false
+	}
+	return t, true
+}
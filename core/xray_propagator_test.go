@@ -0,0 +1,112 @@
+package core_test
+
+import (
+	"github.com/Nordstrom/ctrace-go/core"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+var _ = Describe("XRayPropagator", func() {
+	var p core.Propagator
+
+	BeforeEach(func() {
+		p = core.NewXRayPropagator()
+	})
+
+	extract := func(header string) (core.SpanContext, error) {
+		c, err := p.Extract(opentracing.TextMapCarrier{"x-amzn-trace-id": header})
+		if err != nil {
+			return nil, err
+		}
+		return c.(core.SpanContext), nil
+	}
+
+	It("extracts Root, Parent, and Sampled", func() {
+		c, err := extract("Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=0")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(c.TraceID()).Should(Equal("5759e988bd862e3fe1be46a994272793"))
+		Ω(c.SpanID()).Should(Equal("53995c3f42cd8ad8"))
+		Ω(c.IsSampled()).Should(BeFalse())
+	})
+
+	It("extracts Root without Parent", func() {
+		c, err := extract("Self=1-67891234-12456789abcdef012345678;Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=?")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(c.TraceID()).Should(Equal("5759e988bd862e3fe1be46a994272793"))
+		Ω(c.RawSpanID()).Should(BeZero())
+	})
+
+	It("leaves undecided sampling to the Sampler", func() {
+		for _, sampled := range []bool{true, false} {
+			var buf core.Buffer
+			trc := core.NewWithOptions(core.TracerOptions{
+				Writer:  &buf,
+				Sampler: core.NewConstSampler(sampled),
+			})
+			for _, h := range []string{
+				"Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=?",
+				"Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8",
+			} {
+				c, err := extract(h)
+				Ω(err).ShouldNot(HaveOccurred())
+				sp := trc.StartSpan("op", opentracing.ChildOf(c))
+				Ω(sp.Context().(core.SpanContext).IsSampled()).Should(Equal(sampled), h)
+				sp.Finish()
+			}
+
+			c, _ := extract("Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1")
+			Ω(trc.StartSpan("op", opentracing.ChildOf(c)).Context().(core.SpanContext).IsSampled()).Should(BeTrue())
+		}
+	})
+
+	It("starts root Spans without Parent", func() {
+		var buf core.Buffer
+		trc := core.NewWithOptions(core.TracerOptions{Writer: &buf})
+		c, err := extract("Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1")
+		Ω(err).ShouldNot(HaveOccurred())
+		sp := trc.StartSpan("op", opentracing.ChildOf(c))
+		sc := sp.Context().(core.SpanContext)
+		Ω(sc.TraceID()).Should(Equal("5759e988bd862e3fe1be46a994272793"))
+		Ω(sc.RawSpanID()).ShouldNot(BeZero())
+		sp.Finish()
+
+		out := buf.Spans()[0]
+		Ω(out.ParentID).Should(BeEmpty())
+		Ω(out.References).Should(BeEmpty())
+	})
+
+	It("round-trips", func() {
+		c, _ := extract("Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1")
+		out := opentracing.TextMapCarrier{}
+		Ω(p.Inject(c, out)).Should(Succeed())
+		Ω(out).Should(Equal(opentracing.TextMapCarrier{
+			"X-Amzn-Trace-Id": "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1",
+		}))
+	})
+
+	It("returns not found", func() {
+		_, err := p.Extract(opentracing.TextMapCarrier{})
+		Ω(err).Should(Equal(opentracing.ErrSpanContextNotFound))
+	})
+
+	It("rejects corrupted headers", func() {
+		for _, h := range []string{
+			"Parent=53995c3f42cd8ad8",
+			"Root=2-5759e988-bd862e3fe1be46a994272793",
+			"Root=1-5759e988-bd862e3fe1be46a99427279",
+			"Root=1-5759e988-bd862e3fe1be46a994272793;Parent=xyz",
+			"Root=1-5759e988-bd862e3fe1be46a994272793;Parent=0000000000000000",
+		} {
+			_, err := extract(h)
+			Ω(err).Should(Equal(opentracing.ErrSpanContextCorrupted), h)
+		}
+	})
+
+	It("returns the Root of a header", func() {
+		Ω(core.XRayRoot("Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8")).Should(
+			Equal("1-5759e988-bd862e3fe1be46a994272793"))
+		Ω(core.XRayRoot("")).Should(BeEmpty())
+	})
+})
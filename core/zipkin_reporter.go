@@ -0,0 +1,319 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// maxZipkinPending bounds the Spans whose logs are kept until they finish in
+// Multi-Event Mode, so Spans which never finish do not leak.
+const maxZipkinPending = 10000
+
+// ZipkinReporterOptions allows customizing a SpanReporter created via
+// NewZipkinReporter.
+type ZipkinReporterOptions struct {
+	// ServiceName is the serviceName of the localEndpoint of every Span.  It
+	// defaults to the "service" tag of each Span.
+	ServiceName string
+
+	// Client sends the spans to the collector.  It must not be traced, or
+	// each POST would report further spans.  It defaults to a client with a
+	// timeout of 10 seconds.
+	Client *http.Client
+
+	// AsyncSpanReporterOptions tell how spans are queued and batched, and
+	// how errors are handled.  Each batch is sent in a single POST.
+	AsyncSpanReporterOptions
+}
+
+// zipkinReporter sends finished Spans to a Zipkin collector.
+type zipkinReporter struct {
+	AsyncSpanReporter
+
+	sync.Mutex
+	pending map[uint64][]opentracing.LogRecord
+}
+
+// NewZipkinReporter creates a SpanReporter converting finished Spans to the
+// Zipkin v2 JSON format, and POSTing them in batches to url, e.g.
+// "http://zipkin:9411/api/v2/spans", from a background goroutine.  Tags are
+// mapped as follows, and the other tags are sent as strings:
+//
+//	span.kind                 kind (CLIENT, SERVER, PRODUCER, or CONSUMER)
+//	service                   localEndpoint.serviceName
+//	peer.service              remoteEndpoint.serviceName
+//	peer.ipv4, ipv6, port     remoteEndpoint.ipv4, ipv6, port
+//
+// Logs other than Start-Span and Finish-Span become annotations.  Baggage and
+// references other than the parent are not sent, as Zipkin has no place for
+// them.  The errors of failed POSTs are passed to the ErrorHandler, and their
+// spans counted as dropped.
+func NewZipkinReporter(url string, opts ZipkinReporterOptions) AsyncSpanReporter {
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &zipkinReporter{
+		AsyncSpanReporter: NewAsyncSpanReporter(
+			&zipkinSender{url: url, client: client},
+			&zipkinEncoder{serviceName: opts.ServiceName},
+			opts.AsyncSpanReporterOptions,
+		),
+		pending: make(map[uint64][]opentracing.LogRecord),
+	}
+}
+
+// Report queues finished Spans.  In Multi-Event Mode, the logs of the Span are
+// kept until it finishes, so they can be sent as its annotations.
+func (r *zipkinReporter) Report(sp *SpanData) {
+	id := sp.context.spanID
+	if sp.duration < 0 {
+		logs := zipkinLogs(sp.logs)
+		if len(logs) == 0 {
+			return
+		}
+		r.Lock()
+		if _, ok := r.pending[id]; !ok && len(r.pending) >= maxZipkinPending {
+			r.pending = make(map[uint64][]opentracing.LogRecord)
+		}
+		r.pending[id] = append(r.pending[id], logs...)
+		r.Unlock()
+		return
+	}
+
+	r.Lock()
+	logs, ok := r.pending[id]
+	delete(r.pending, id)
+	r.Unlock()
+	if ok {
+		merged := *sp
+		merged.logs = append(logs, zipkinLogs(sp.logs)...)
+		sp = &merged
+	}
+	r.AsyncSpanReporter.Report(sp)
+}
+
+// zipkinLogs returns the logs which are sent as annotations.
+func zipkinLogs(logs []opentracing.LogRecord) []opentracing.LogRecord {
+	var kept []opentracing.LogRecord
+	for _, lr := range logs {
+		if lr.Timestamp.IsZero() {
+			continue
+		}
+		if event := logEvent(lr); event == "Start-Span" || event == "Finish-Span" {
+			continue
+		}
+		kept = append(kept, lr)
+	}
+	return kept
+}
+
+// logEvent returns the "event" field of lr if it is the only field, or "".
+func logEvent(lr opentracing.LogRecord) string {
+	if len(lr.Fields) == 1 && lr.Fields[0].Key() == "event" {
+		if s, ok := lr.Fields[0].Value().(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// zipkinEncoder encodes a finished Span as a Zipkin v2 span followed by a
+// comma, so that a batch of them is a JSON array without its brackets and
+// last comma.
+type zipkinEncoder struct {
+	jsonEncoder
+	serviceName string
+}
+
+// Tags encoded as fields of the Zipkin span rather than as tags.
+var zipkinFieldTags = map[string]bool{
+	"span.kind":    true,
+	"service":      true,
+	"peer.service": true,
+	"peer.ipv4":    true,
+	"peer.ipv6":    true,
+	"peer.port":    true,
+}
+
+func (enc *zipkinEncoder) Encode(sp *SpanData) []byte {
+	bytes := make([]byte, 0, 1024)
+
+	bytes = append(bytes, '{')
+	bytes = enc.encodeKeyTraceID(bytes, "traceId", sp.context.traceID)
+	if sp.parentID != 0 {
+		bytes = enc.encodeKeyID(bytes, "parentId", sp.parentID)
+	}
+	bytes = enc.encodeKeyID(bytes, "id", sp.context.spanID)
+	if kind, ok := sp.tags["span.kind"].(string); ok {
+		switch kind = strings.ToUpper(kind); kind {
+		case "CLIENT", "SERVER", "PRODUCER", "CONSUMER":
+			bytes = enc.encodeKeyString(bytes, "kind", kind)
+		}
+	}
+	bytes = enc.encodeKeyString(bytes, "name", sp.operation)
+	bytes = enc.encodeKeyInt(bytes, "timestamp", sp.start.UnixNano()/1e3)
+	bytes = enc.encodeKeyInt(bytes, "duration", sp.duration.Nanoseconds()/1e3)
+	if sp.context.debug {
+		bytes = enc.encodeKeyBool(bytes, "debug", true)
+	}
+
+	serviceName := enc.serviceName
+	if serviceName == "" {
+		serviceName, _ = sp.tags["service"].(string)
+	}
+	if serviceName != "" {
+		bytes = enc.encodeKey(bytes, "localEndpoint")
+		bytes = append(bytes, '{')
+		bytes = enc.encodeKeyString(bytes, "serviceName", serviceName)
+		bytes = append(bytes, '}')
+	}
+	bytes = enc.encodeRemoteEndpoint(bytes, sp.tags)
+	bytes = enc.encodeAnnotations(bytes, sp.logs)
+	bytes = enc.encodeTags(bytes, sp.tags)
+	bytes = append(bytes, '}', ',')
+
+	return bytes
+}
+
+func (enc *zipkinEncoder) encodeRemoteEndpoint(
+	bytes []byte,
+	tags map[string]interface{},
+) []byte {
+	mark := len(bytes)
+	bytes = enc.encodeKey(bytes, "remoteEndpoint")
+	bytes = append(bytes, '{')
+	start := len(bytes)
+	if v, ok := tags["peer.service"].(string); ok {
+		bytes = enc.encodeKeyString(bytes, "serviceName", v)
+	}
+	if v, ok := tags["peer.ipv4"]; ok {
+		bytes = enc.encodeKeyString(bytes, "ipv4", zipkinIPv4(v))
+	}
+	if v, ok := tags["peer.ipv6"].(string); ok {
+		bytes = enc.encodeKeyString(bytes, "ipv6", v)
+	}
+	if v, ok := zipkinPort(tags["peer.port"]); ok {
+		bytes = enc.encodeKeyInt(bytes, "port", v)
+	}
+	if len(bytes) == start {
+		return bytes[:mark]
+	}
+	return append(bytes, '}')
+}
+
+// zipkinIPv4 returns an IPv4 address tag, which ext.PeerHostIPv4 sets as a
+// uint32, in dotted notation.
+func zipkinIPv4(v interface{}) string {
+	if ip, ok := v.(uint32); ok {
+		return fmt.Sprintf("%d.%d.%d.%d", byte(ip>>24), byte(ip>>16), byte(ip>>8), byte(ip))
+	}
+	return fmt.Sprint(v)
+}
+
+func zipkinPort(v interface{}) (int64, bool) {
+	switch p := v.(type) {
+	case int:
+		return int64(p), true
+	case int32:
+		return int64(p), true
+	case int64:
+		return p, true
+	case uint16:
+		return int64(p), true
+	case uint32:
+		return int64(p), true
+	}
+	return 0, false
+}
+
+// encodeAnnotations encodes each log as an annotation whose value is its
+// event, or the JSON object of its fields if it has others.
+func (enc *zipkinEncoder) encodeAnnotations(
+	bytes []byte,
+	logs []opentracing.LogRecord,
+) []byte {
+	logs = zipkinLogs(logs)
+	if len(logs) == 0 {
+		return bytes
+	}
+	bytes = enc.encodeKey(bytes, "annotations")
+	bytes = append(bytes, '[')
+	for i, lr := range logs {
+		if i > 0 {
+			bytes = append(bytes, ',')
+		}
+		bytes = append(bytes, '{')
+		bytes = enc.encodeKeyInt(bytes, "timestamp", lr.Timestamp.UnixNano()/1e3)
+		value := logEvent(lr)
+		if value == "" {
+			fe := logFieldEncoder{enc: &enc.jsonEncoder, bytes: []byte{'{'}}
+			for _, f := range lr.Fields {
+				marshalField(f, &fe)
+			}
+			value = string(append(fe.bytes, '}'))
+		}
+		bytes = enc.encodeKeyString(bytes, "value", value)
+		bytes = append(bytes, '}')
+	}
+	return append(bytes, ']')
+}
+
+// encodeTags encodes the tags not mapped to fields, in sorted order, with
+// their values as strings as Zipkin requires.
+func (enc *zipkinEncoder) encodeTags(bytes []byte, tags map[string]interface{}) []byte {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		if !zipkinFieldTags[k] {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return bytes
+	}
+	names, keys := sortedValidKeys(keys)
+	bytes = enc.encodeKey(bytes, "tags")
+	bytes = append(bytes, '{')
+	for i, k := range keys {
+		v, ok := tags[k].(string)
+		if !ok {
+			v = string(enc.encodeValue(nil, tags[k], 0, maxValueSize))
+		}
+		bytes = enc.encodeKeyString(bytes, names[i], v)
+	}
+	return append(bytes, '}')
+}
+
+// zipkinSender is an io.Writer POSTing batches encoded by zipkinEncoder as a
+// JSON array.
+type zipkinSender struct {
+	url    string
+	client *http.Client
+}
+
+func (s *zipkinSender) Write(batch []byte) (int, error) {
+	body := make([]byte, 0, len(batch)+1)
+	body = append(body, '[')
+	body = append(body, bytes.TrimSuffix(batch, []byte{','})...)
+	body = append(body, ']')
+
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain the body so the connection can be reused.
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, fmt.Errorf("zipkin: POST %s: %s", s.url, resp.Status)
+	}
+	return len(batch), nil
+}
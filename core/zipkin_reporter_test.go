@@ -0,0 +1,238 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/Nordstrom/ctrace-go/core"
+	"github.com/Nordstrom/ctrace-go/ext"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// zipkinCollector is a stand-in Zipkin collector recording the POSTed
+// batches.
+type zipkinCollector struct {
+	sync.Mutex
+	batches [][]map[string]interface{}
+	status  int
+}
+
+func (c *zipkinCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer GinkgoRecover()
+	Ω(r.Method).Should(Equal("POST"))
+	Ω(r.URL.Path).Should(Equal("/api/v2/spans"))
+	Ω(r.Header.Get("Content-Type")).Should(Equal("application/json"))
+
+	body, err := ioutil.ReadAll(r.Body)
+	Ω(err).ShouldNot(HaveOccurred())
+	var batch []map[string]interface{}
+	Ω(json.Unmarshal(body, &batch)).Should(Succeed())
+
+	c.Lock()
+	defer c.Unlock()
+	c.batches = append(c.batches, batch)
+	if c.status != 0 {
+		w.WriteHeader(c.status)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (c *zipkinCollector) spans() []map[string]interface{} {
+	c.Lock()
+	defer c.Unlock()
+	var spans []map[string]interface{}
+	for _, b := range c.batches {
+		spans = append(spans, b...)
+	}
+	return spans
+}
+
+var _ = Describe("ZipkinReporter", func() {
+	var (
+		collector *zipkinCollector
+		server    *httptest.Server
+		reporter  core.AsyncSpanReporter
+		trc       core.Tracer
+	)
+
+	newTracer := func(multiEvent bool, opts core.ZipkinReporterOptions) {
+		reporter = core.NewZipkinReporter(server.URL+"/api/v2/spans", opts)
+		trc = core.NewWithOptions(core.TracerOptions{
+			MultiEvent:  multiEvent,
+			ServiceName: "orders",
+			Reporter:    reporter,
+		})
+	}
+
+	flush := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		Ω(trc.Flush(ctx)).Should(Succeed())
+	}
+
+	BeforeEach(func() {
+		collector = &zipkinCollector{}
+		server = httptest.NewServer(collector)
+	})
+
+	AfterEach(func() {
+		reporter.Close(context.Background())
+		server.Close()
+	})
+
+	It("converts finished spans to Zipkin v2 JSON", func() {
+		newTracer(true, core.ZipkinReporterOptions{})
+		parent := trc.StartSpan("GET /orders", ext.SpanKindServer())
+		child := trc.StartSpan("GET /stock",
+			opentracing.ChildOf(parent.Context()),
+			ext.SpanKindClient(),
+			ext.PeerService("stock"),
+			ext.PeerHostIPv4(0x0a000001),
+			ext.PeerPort(8080),
+			ext.HTTPStatusCode(200),
+		)
+		child.LogFields(log.String("event", "retry"))
+		child.LogFields(log.String("event", "cache miss"), log.Int("waited", 3))
+		child.Finish()
+		parent.Finish()
+		flush()
+
+		spans := collector.spans()
+		Ω(spans).Should(HaveLen(2))
+		c, p := spans[0], spans[1]
+		pc := parent.Context().(core.SpanContext)
+		cc := child.Context().(core.SpanContext)
+
+		Ω(p["traceId"]).Should(Equal(pc.TraceID()))
+		Ω(p["id"]).Should(Equal(pc.SpanID()))
+		Ω(p).ShouldNot(HaveKey("parentId"))
+		Ω(p["kind"]).Should(Equal("SERVER"))
+		Ω(p["name"]).Should(Equal("GET /orders"))
+		Ω(p["timestamp"]).Should(BeNumerically(">", 0))
+		Ω(p).Should(HaveKey("duration"))
+		Ω(p["localEndpoint"]).Should(Equal(map[string]interface{}{"serviceName": "orders"}))
+		Ω(p).ShouldNot(HaveKey("remoteEndpoint"))
+		Ω(p).ShouldNot(HaveKey("annotations"))
+		Ω(p).ShouldNot(HaveKey("tags"))
+
+		Ω(c["traceId"]).Should(Equal(pc.TraceID()))
+		Ω(c["id"]).Should(Equal(cc.SpanID()))
+		Ω(c["parentId"]).Should(Equal(pc.SpanID()))
+		Ω(c["kind"]).Should(Equal("CLIENT"))
+		Ω(c["remoteEndpoint"]).Should(Equal(map[string]interface{}{
+			"serviceName": "stock",
+			"ipv4":        "10.0.0.1",
+			"port":        8080.0,
+		}))
+		Ω(c["tags"]).Should(Equal(map[string]interface{}{"http.status_code": "200"}))
+
+		annotations := c["annotations"].([]interface{})
+		Ω(annotations).Should(HaveLen(2))
+		Ω(annotations[0].(map[string]interface{})["value"]).Should(Equal("retry"))
+		Ω(annotations[1].(map[string]interface{})["value"]).Should(Equal(
+			`{"event":"cache miss","waited":3}`))
+		Ω(annotations[0].(map[string]interface{})["timestamp"]).Should(BeNumerically(">", 0))
+	})
+
+	It("sends annotations in Single-Event Mode", func() {
+		newTracer(false, core.ZipkinReporterOptions{ServiceName: "api"})
+		sp := trc.StartSpan("op", opentracing.Tag{Key: "service", Value: "ignored"})
+		sp.LogFields(log.String("event", "retry"))
+		sp.Finish()
+		flush()
+
+		spans := collector.spans()
+		Ω(spans).Should(HaveLen(1))
+		Ω(spans[0]["localEndpoint"]).Should(Equal(map[string]interface{}{"serviceName": "api"}))
+		Ω(spans[0]["annotations"]).Should(HaveLen(1))
+	})
+
+	It("sends spans in batches", func() {
+		newTracer(true, core.ZipkinReporterOptions{
+			AsyncSpanReporterOptions: core.AsyncSpanReporterOptions{
+				BatchSize:     2,
+				FlushInterval: time.Hour,
+			},
+		})
+		for i := 0; i < 3; i++ {
+			trc.StartSpan("op").Finish()
+		}
+		Eventually(func() int {
+			collector.Lock()
+			defer collector.Unlock()
+			return len(collector.batches)
+		}).Should(Equal(1))
+		flush()
+
+		collector.Lock()
+		defer collector.Unlock()
+		Ω(collector.batches).Should(HaveLen(2))
+		Ω(collector.batches[0]).Should(HaveLen(2))
+		Ω(collector.batches[1]).Should(HaveLen(1))
+	})
+
+	It("does not send unfinished spans", func() {
+		newTracer(true, core.ZipkinReporterOptions{})
+		sp := trc.StartSpan("op")
+		sp.LogFields(log.String("event", "retry"))
+		flush()
+		Ω(collector.spans()).Should(BeEmpty())
+	})
+
+	It("keeps sending after the collector rejects a batch", func() {
+		var errs []error
+		collector.status = http.StatusBadRequest
+		newTracer(true, core.ZipkinReporterOptions{
+			AsyncSpanReporterOptions: core.AsyncSpanReporterOptions{
+				ErrorHandler: func(err error) { errs = append(errs, err) },
+			},
+		})
+		trc.StartSpan("op1").Finish()
+		flush()
+		Ω(reporter.Dropped()).Should(Equal(uint64(1)))
+		Ω(errs).Should(HaveLen(1))
+		Ω(errs[0].Error()).Should(ContainSubstring("400 Bad Request"))
+
+		collector.Lock()
+		collector.status = 0
+		collector.Unlock()
+		trc.StartSpan("op2").Finish()
+		flush()
+
+		spans := collector.spans()
+		Ω(spans).Should(HaveLen(2))
+		Ω(spans[1]["name"]).Should(Equal("op2"))
+		Ω(reporter.Dropped()).Should(Equal(uint64(1)))
+	})
+
+	It("sends flushed spans in batches", func() {
+		newTracer(true, core.ZipkinReporterOptions{
+			AsyncSpanReporterOptions: core.AsyncSpanReporterOptions{
+				BatchSize:     2,
+				FlushInterval: time.Hour,
+			},
+		})
+		for i := 0; i < 5; i++ {
+			trc.StartSpan("op").Finish()
+		}
+		flush()
+
+		collector.Lock()
+		defer collector.Unlock()
+		total := 0
+		for _, b := range collector.batches {
+			Ω(len(b)).Should(BeNumerically("<=", 2))
+			total += len(b)
+		}
+		Ω(total).Should(Equal(5))
+	})
+})
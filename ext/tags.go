@@ -101,6 +101,14 @@ const (
 
 	// HTTPUserAgentKey is the key for the UserAgent tag
 	HTTPUserAgentKey = "http.user_agent"
+
+	//////////////////////////////////////////////////////////////////////
+	// AWS Tags
+	//////////////////////////////////////////////////////////////////////
+
+	// AWSXRayTraceIDKey is the key for a tag that records the Root trace ID of
+	// the AWS X-Ray trace header of the request.
+	AWSXRayTraceIDKey = "aws.xray.trace_id"
 )
 
 var (
@@ -191,6 +199,13 @@ var (
 
 	// HTTPUserAgent is the
 	HTTPUserAgent = stringTagName(HTTPUserAgentKey)
+
+	//////////////////////////////////////////////////////////////////////
+	// AWS Tags
+	//////////////////////////////////////////////////////////////////////
+
+	// AWSXRayTraceID records the Root trace ID of the AWS X-Ray trace header
+	AWSXRayTraceID = stringTagName(AWSXRayTraceIDKey)
 )
 
 func spanKindTag(k string, v string) func() opentracing.Tag {
@@ -74,4 +74,9 @@ var _ = Describe("Tags", func() {
 	It("PeerPort", func() {
 		Ω(PeerPort(123)).Should(Equal(ot.Tag{Key: "peer.port", Value: uint16(123)}))
 	})
+
+	It("AWSXRayTraceID", func() {
+		Ω(AWSXRayTraceID("1-5759e988-bd862e3fe1be46a994272793")).Should(Equal(
+			ot.Tag{Key: "aws.xray.trace_id", Value: "1-5759e988-bd862e3fe1be46a994272793"}))
+	})
 })
@@ -1,6 +1,9 @@
 package ctrace
 
 import (
+	"context"
+	"time"
+
 	"github.com/Nordstrom/ctrace-go/core"
 	opentracing "github.com/opentracing/opentracing-go"
 	// godebug "github.com/tj/go-debug"
@@ -20,15 +23,23 @@ func init() {
 	Init(TracerOptions{})
 }
 
-// Init initializes the global Tracer returned by Global().
+// initCloseTimeout bounds how long Init waits for the previous global Tracer
+// to write its buffered events.
+const initCloseTimeout = 5 * time.Second
+
+// Init initializes the global Tracer returned by Global().  The previous
+// global Tracer is closed, so its buffered events are written, waiting up to
+// 5 seconds, and its background goroutines stop.  Spans it finishes later are
+// not reported.
 func Init(opts TracerOptions) core.Tracer {
-	opentracing.SetGlobalTracer(core.NewWithOptions(
-		core.TracerOptions{
-			MultiEvent:  opts.MultiEvent,
-			Writer:      opts.Writer,
-			ServiceName: opts.ServiceName,
-		}))
+	prev, _ := opentracing.GlobalTracer().(core.Tracer)
+	opentracing.SetGlobalTracer(core.NewWithOptions(core.TracerOptions(opts)))
 
+	if prev != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), initCloseTimeout)
+		defer cancel()
+		prev.Close(ctx)
+	}
 	return Global()
 }
 
@@ -36,3 +47,10 @@ func Init(opts TracerOptions) core.Tracer {
 func Global() core.Tracer {
 	return opentracing.GlobalTracer().(core.Tracer)
 }
+
+// Shutdown flushes all buffered trace events of the global Tracer and closes
+// it.  Call it before a CLI tool or Lambda function returns, so no events are
+// lost.  It returns ctx.Err() if ctx is done before all events are written.
+func Shutdown(ctx context.Context) error {
+	return Global().Close(ctx)
+}
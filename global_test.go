@@ -0,0 +1,49 @@
+package ctrace_test
+
+import (
+	"bufio"
+	"context"
+	"time"
+
+	ctrace "github.com/Nordstrom/ctrace-go"
+	"github.com/Nordstrom/ctrace-go/core"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Shutdown", func() {
+	AfterEach(func() {
+		ctrace.Init(ctrace.TracerOptions{})
+	})
+
+	It("flushes the global Tracer", func() {
+		var buf core.Buffer
+		ctrace.Init(ctrace.TracerOptions{Writer: bufio.NewWriter(&buf)})
+		ctrace.Global().StartSpan("op").Finish()
+		Ω(buf.Len()).Should(BeZero())
+
+		Ω(ctrace.Shutdown(context.Background())).Should(Succeed())
+		Ω(buf.Spans()[0].Operation).Should(Equal("op"))
+	})
+})
+
+var _ = Describe("Init", func() {
+	AfterEach(func() {
+		ctrace.Init(ctrace.TracerOptions{})
+	})
+
+	It("closes the previous global Tracer", func() {
+		var buf core.Buffer
+		ctrace.Init(ctrace.TracerOptions{
+			Reporter: core.NewAsyncSpanReporter(&buf, core.NewSpanEncoder(),
+				core.AsyncSpanReporterOptions{FlushInterval: time.Hour}),
+		})
+		prev := ctrace.Global()
+		prev.StartSpan("op").Finish()
+
+		ctrace.Init(ctrace.TracerOptions{})
+		Ω(buf.Spans()[0].Operation).Should(Equal("op"))
+		prev.StartSpan("late").Finish()
+		Ω(buf.Spans()).Should(HaveLen(1))
+	})
+})
@@ -89,8 +89,8 @@ func (t *tracedHTTPClientTransport) RoundTrip(
 
 	tracer := Global()
 	span, _ := StartSpanFromContext(r.Context(), op, opts...)
-	tracer.Inject(
-		span.Context(),
+	tracer.InjectSpan(
+		span,
 		opentracing.HTTPHeaders,
 		opentracing.HTTPHeadersCarrier(r.Header))
 
@@ -202,6 +202,10 @@ func TracedHTTPHandler(
 		parentCtx, _ := tracer.Extract(core.HTTPHeaders, core.HTTPHeadersCarrier(r.Header))
 
 		config := optioniallyInterceptHTTP(interceptor, r)
+		parent := ChildOf(parentCtx)
+		if config.Untrusted {
+			parent = core.Link(parentCtx)
+		}
 
 		var op string
 		if config.OperationName != "" {
@@ -210,7 +214,7 @@ func TracedHTTPHandler(
 			op = httpOperationName(mux, muxFound, r)
 		}
 		opts := []opentracing.StartSpanOption{
-			ChildOf(parentCtx),
+			parent,
 			ext.SpanKindServer(),
 			ext.Component("ctrace.TracedHttpHandler"),
 			ext.HTTPRemoteAddr(r.RemoteAddr),
@@ -219,6 +223,13 @@ func TracedHTTPHandler(
 			ext.HTTPUserAgent(r.UserAgent()),
 		}
 
+		// An untrusted caller could claim any X-Ray trace, so its root is only
+		// recorded along with a trusted parent.
+		if !config.Untrusted {
+			if root := core.XRayRoot(r.Header.Get(core.XRayTraceHeader)); root != "" {
+				opts = append(opts, ext.AWSXRayTraceID(root))
+			}
+		}
 		if config.OperationName != "" {
 			op = config.OperationName
 		}
@@ -167,6 +167,56 @@ var _ = Describe("http", func() {
 				Expect(tags["error"]).To(Equal(true))
 				Expect(tags["http.status_code"]).To(Equal(float64(400)))
 			})
+
+			It("records debug requests in Multi-Event Mode", func() {
+				req, _ := http.NewRequest("GET", srv.URL+"/test/foo", nil)
+				req.Header.Set("Ct-Debug", "1")
+				_, err := http.DefaultClient.Do(req)
+
+				Expect(err).ShouldNot(HaveOccurred())
+
+				spans := buf.Spans()
+				Expect(spans).To(HaveLen(2))
+				Expect(spans[0].Logs[0]["event"]).To(Equal("Start-Span"))
+				Expect(spans[1].Logs[0]["event"]).To(Equal("Finish-Span"))
+			})
+
+			It("records the X-Ray trace ID", func() {
+				req, _ := http.NewRequest("GET", srv.URL+"/test/foo", nil)
+				req.Header.Set("X-Amzn-Trace-Id", "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1")
+				_, err := http.DefaultClient.Do(req)
+
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(buf.Spans()[0].Tags["aws.xray.trace_id"]).To(Equal("1-5759e988-bd862e3fe1be46a994272793"))
+			})
+
+			It("ignores the X-Ray trace ID of untrusted requests", func() {
+				untrusted := ctrace.TrustInterceptor(func(r *http.Request) bool { return false })
+				usrv := httptest.NewServer(ctrace.TracedHTTPHandler(mux, untrusted))
+				defer usrv.Close()
+				req, _ := http.NewRequest("GET", usrv.URL+"/test/foo", nil)
+				req.Header.Set("X-Amzn-Trace-Id", "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1")
+				_, err := http.DefaultClient.Do(req)
+
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(buf.Spans()[0].Tags).ShouldNot(HaveKey("aws.xray.trace_id"))
+			})
+
+			It("continues W3C traces", func() {
+				ctrace.Init(ctrace.TracerOptions{
+					Writer:                &buf.Buffer,
+					HTTPHeadersPropagator: core.NewW3CPropagator(),
+				})
+				req, _ := http.NewRequest("GET", srv.URL+"/test/foo", nil)
+				req.Header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+				_, err := http.DefaultClient.Do(req)
+
+				Expect(err).ShouldNot(HaveOccurred())
+
+				sp := buf.Spans()[0]
+				Expect(sp.TraceID).To(Equal("0af7651916cd43dd8448eb211c80319c"))
+				Expect(sp.ParentID).To(Equal("b7ad6b7169203331"))
+			})
 		})
 
 		Context("for ServeMux or ListenAndServe, ignored Paths", func() {
@@ -248,6 +298,51 @@ var _ = Describe("http", func() {
 			})
 		})
 
+		Context("with TrustInterceptor", func() {
+			var baggage string
+
+			BeforeEach(func() {
+				mux.HandleFunc("/test/", func(w http.ResponseWriter, r *http.Request) {
+					baggage = ctrace.SpanFromContext(r.Context()).BaggageItem("user")
+					w.WriteHeader(200)
+				})
+				trusted := ctrace.TrustHeader("X-Internal", func(v string) bool { return v == "yes" })
+				srv = httptest.NewServer(ctrace.TracedHTTPHandler(mux, ctrace.TrustInterceptor(trusted)))
+			})
+
+			AfterEach(func() {
+				srv.Close()
+			})
+
+			request := func(internal string) core.SpanModel {
+				req, _ := http.NewRequest("GET", srv.URL+"/test/foo", nil)
+				req.Header.Set("Ct-Trace-Id", "7b")
+				req.Header.Set("Ct-Span-Id", "1c8")
+				req.Header.Set("Ct-Bag-User", "admin")
+				req.Header.Set("X-Internal", internal)
+				_, err := http.DefaultClient.Do(req)
+				Expect(err).ShouldNot(HaveOccurred())
+				return buf.Spans()[0]
+			}
+
+			It("continues trusted traces", func() {
+				sp := request("yes")
+				Expect(sp.TraceID).To(Equal("000000000000007b"))
+				Expect(sp.ParentID).To(Equal("00000000000001c8"))
+				Expect(baggage).To(Equal("admin"))
+			})
+
+			It("restarts untrusted traces with a link", func() {
+				sp := request("no")
+				Expect(sp.TraceID).NotTo(Equal("000000000000007b"))
+				Expect(sp.ParentID).To(BeEmpty())
+				Expect(sp.References).To(Equal([]core.ReferenceModel{
+					{Type: "follows_from", TraceID: "000000000000007b", SpanID: "00000000000001c8"},
+				}))
+				Expect(baggage).To(BeEmpty())
+			})
+		})
+
 		Context("for Handle", func() {
 			It("records default OperationName", func() {
 				mux.Handle(
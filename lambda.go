@@ -2,6 +2,9 @@ package ctrace
 
 import (
 	"context"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/Nordstrom/ctrace-go/core"
 	"github.com/Nordstrom/ctrace-go/ext"
@@ -10,6 +13,10 @@ import (
 	opentracing "github.com/opentracing/opentracing-go"
 )
 
+// lambdaFlushTimeout bounds how long a traced Lambda handler waits for trace
+// events to be written before it returns and the container is frozen.
+const lambdaFlushTimeout = time.Second
+
 // LambdaFunction is the defined function for Lambda handlers
 type LambdaFunction func(
 	evt *apigatewayproxyevt.Event,
@@ -39,9 +46,20 @@ func optioniallyInterceptLambda(
 	return SpanConfig{}
 }
 
+// lambdaXRayHeader returns the X-Ray trace header of the request, falling back
+// to the one Lambda sets in the environment when active tracing is enabled.
+func lambdaXRayHeader(headers map[string]string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, core.XRayTraceHeader) {
+			return v
+		}
+	}
+	return os.Getenv("_X_AMZN_TRACE_ID")
+}
+
 // TracedAPIGwLambdaProxyHandler is a decorator (wrapper) that wraps the Lambda
 // handler function for tracing.  It handles starting a span when the handler
-// is called and finishing it upon completion.  To customize the OperationName
+// is called and finishing and flushing it upon completion.  To customize the OperationName
 // or Tags pass in a LambdaFunctionInterceptor
 func TracedAPIGwLambdaProxyHandler(
 	fn TracedLambdaFunction,
@@ -54,6 +72,10 @@ func TracedAPIGwLambdaProxyHandler(
 		tracer := Global()
 		parentCtx, _ := tracer.Extract(core.TextMap, core.TextMapCarrier(evt.Headers))
 		config := optioniallyInterceptLambda(evt, lambdaCtx, interceptor...)
+		parent := ChildOf(parentCtx)
+		if config.Untrusted {
+			parent = core.Link(parentCtx)
+		}
 
 		var op string
 		if config.OperationName != "" {
@@ -62,7 +84,7 @@ func TracedAPIGwLambdaProxyHandler(
 			op = lambdaCtx.FunctionName
 		}
 		opts := []opentracing.StartSpanOption{
-			ChildOf(parentCtx),
+			parent,
 			ext.SpanKindServer(),
 			ext.Component("ctrace.TracedAPIGwLambdaProxyHandler"),
 			ext.HTTPRemoteAddr(httpRemoteAddr(evt.Headers)),
@@ -71,11 +93,25 @@ func TracedAPIGwLambdaProxyHandler(
 			ext.HTTPUserAgent(httpUserAgent(evt.Headers)),
 		}
 
+		// An untrusted caller could claim any X-Ray trace, so only the header
+		// Lambda sets in the environment is recorded for it.
+		xrayHeaders := evt.Headers
+		if config.Untrusted {
+			xrayHeaders = nil
+		}
+		if root := core.XRayRoot(lambdaXRayHeader(xrayHeaders)); root != "" {
+			opts = append(opts, ext.AWSXRayTraceID(root))
+		}
 		if len(config.Tags) > 0 {
 			opts = append(opts, config.Tags...)
 		}
 		span := tracer.StartSpan(op, opts...)
-		defer span.Finish()
+		defer func() {
+			span.Finish()
+			flushCtx, cancel := context.WithTimeout(context.Background(), lambdaFlushTimeout)
+			defer cancel()
+			tracer.Flush(flushCtx)
+		}()
 
 		ctx := ContextWithSpan(context.Background(), span)
 		rtn, err := fn(ctx, evt, lambdaCtx)
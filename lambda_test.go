@@ -50,6 +50,9 @@ var _ = Describe("TracedAPIGwLambdaProxyHandler", func() {
 			evt *apigatewayproxyevt.Event,
 			lambdaCtx *runtime.Context,
 		) ctrace.SpanConfig {
+			if evt.Path == "/untrusted" {
+				return ctrace.SpanConfig{Untrusted: true}
+			}
 			if evt.Path == "/intercept" {
 				return ctrace.ConfigSpan(
 					"newopname",
@@ -105,6 +108,50 @@ var _ = Describe("TracedAPIGwLambdaProxyHandler", func() {
 		})
 	})
 
+	Context("with X-Ray trace header", func() {
+		JustBeforeEach(func() {
+			tracedHandler(
+				&apigatewayproxyevt.Event{
+					Path:       "/ok",
+					HTTPMethod: "GET",
+					Headers: map[string]string{
+						"x-amzn-trace-id": "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1",
+					},
+				},
+				&runtime.Context{
+					FunctionName: "my-func",
+				},
+			)
+			span = buf.Spans()[0]
+		})
+
+		It("records the X-Ray trace ID", func() {
+			Expect(span.Tags["aws.xray.trace_id"]).To(Equal("1-5759e988-bd862e3fe1be46a994272793"))
+		})
+	})
+
+	Context("with untrusted X-Ray trace header", func() {
+		JustBeforeEach(func() {
+			tracedHandler(
+				&apigatewayproxyevt.Event{
+					Path:       "/untrusted",
+					HTTPMethod: "GET",
+					Headers: map[string]string{
+						"x-amzn-trace-id": "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1",
+					},
+				},
+				&runtime.Context{
+					FunctionName: "my-func",
+				},
+			)
+			span = buf.Spans()[0]
+		})
+
+		It("ignores the X-Ray trace ID", func() {
+			Expect(span.Tags).ShouldNot(HaveKey("aws.xray.trace_id"))
+		})
+	})
+
 	Context("with Interceptor", func() {
 		JustBeforeEach(func() {
 			tracedHandler(
@@ -18,6 +18,11 @@ type SpanConfig struct {
 
 	// Tags are the custom start span options decided by interceptor.
 	Tags []opentracing.StartSpanOption
+
+	// Untrusted marks a request from an untrusted caller.  Its span starts a
+	// new trace without the caller's baggage, linked to the trace the caller
+	// claims.  See TrustPolicy.
+	Untrusted bool
 }
 
 // ConfigSpan function is used by middleware interceptors to construct a SpanConfig
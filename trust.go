@@ -0,0 +1,85 @@
+package ctrace
+
+import (
+	"net"
+	"net/http"
+)
+
+// TrustPolicy decides whether the trace claimed by the headers of an incoming
+// request is continued.  Requests from untrusted callers start a new trace
+// without their baggage, linked to the claimed trace by a FollowsFrom
+// reference.  Use TrustInterceptor to apply a TrustPolicy to
+// TracedHTTPHandler.
+type TrustPolicy func(r *http.Request) bool
+
+// TrustNetworks returns a TrustPolicy trusting requests whose remote address
+// is in one of the given CIDRs, e.g. "10.0.0.0/8".
+func TrustNetworks(cidrs ...string) (TrustPolicy, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return func(r *http.Request) bool {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return false
+		}
+		for _, n := range nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// TrustHeader returns a TrustPolicy trusting requests whose header named key
+// satisfies match, e.g. a shared secret set by an API gateway.
+func TrustHeader(key string, match func(value string) bool) TrustPolicy {
+	return func(r *http.Request) bool {
+		return match(r.Header.Get(key))
+	}
+}
+
+// TrustAny returns a TrustPolicy trusting requests trusted by any of the
+// given policies.
+func TrustAny(policies ...TrustPolicy) TrustPolicy {
+	return func(r *http.Request) bool {
+		for _, p := range policies {
+			if p(r) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// TrustInterceptor returns a TracedHTTPInterceptor marking requests not
+// trusted by policy as Untrusted.  The SpanConfig is otherwise returned by
+// interceptor, if given.  For example:
+//
+//	trusted, _ := ctrace.TrustNetworks("10.0.0.0/8")
+//	ctrace.TracedHTTPHandler(
+//	  http.DefaultServeMux,
+//	  ctrace.TrustInterceptor(trusted),
+//	)
+func TrustInterceptor(
+	policy TrustPolicy,
+	interceptor ...TracedHTTPInterceptor,
+) TracedHTTPInterceptor {
+	return func(r *http.Request) SpanConfig {
+		config := optioniallyInterceptHTTP(interceptor, r)
+		if !policy(r) {
+			config.Untrusted = true
+		}
+		return config
+	}
+}
@@ -0,0 +1,62 @@
+package ctrace_test
+
+import (
+	"net/http"
+
+	ctrace "github.com/Nordstrom/ctrace-go"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TrustPolicy", func() {
+	request := func(remoteAddr string) *http.Request {
+		r, _ := http.NewRequest("GET", "http://example.com/", nil)
+		r.RemoteAddr = remoteAddr
+		return r
+	}
+
+	Describe("TrustNetworks", func() {
+		It("trusts remote addresses in the networks", func() {
+			trusted, err := ctrace.TrustNetworks("10.0.0.0/8", "fd00::/8")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(trusted(request("10.1.2.3:5678"))).To(BeTrue())
+			Expect(trusted(request("[fd00::1]:5678"))).To(BeTrue())
+			Expect(trusted(request("192.168.1.1:5678"))).To(BeFalse())
+			Expect(trusted(request("garbage"))).To(BeFalse())
+		})
+
+		It("rejects invalid CIDRs", func() {
+			_, err := ctrace.TrustNetworks("10.0.0.0")
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Describe("TrustAny", func() {
+		It("trusts requests trusted by any policy", func() {
+			local, _ := ctrace.TrustNetworks("127.0.0.0/8")
+			trusted := ctrace.TrustAny(local, ctrace.TrustHeader("X-Internal", func(v string) bool {
+				return v == "yes"
+			}))
+			r := request("10.1.2.3:5678")
+			Expect(trusted(r)).To(BeFalse())
+			r.Header.Set("X-Internal", "yes")
+			Expect(trusted(r)).To(BeTrue())
+			Expect(trusted(request("127.0.0.1:5678"))).To(BeTrue())
+		})
+	})
+
+	Describe("TrustInterceptor", func() {
+		It("marks untrusted requests and keeps the interceptor's config", func() {
+			interceptor := ctrace.TrustInterceptor(
+				func(r *http.Request) bool { return false },
+				func(r *http.Request) ctrace.SpanConfig {
+					return ctrace.SpanConfig{OperationName: "op"}
+				},
+			)
+			Expect(interceptor(request("10.1.2.3:5678"))).To(Equal(ctrace.SpanConfig{
+				OperationName: "op",
+				Untrusted:     true,
+			}))
+		})
+	})
+})